@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"rosen-bridge/tss-api/models"
+)
+
+const (
+	defaultAuthWindow = 300
+	maxNonceLength    = 64
+)
+
+type hmacAuth struct {
+	lock   sync.Mutex
+	secret []byte
+	window time.Duration
+	nonces map[string]time.Time
+}
+
+//	Constructor of the authentication middleware of the control API, requests are authenticated with an hmac
+//	signature of the shared secret (default) or a client certificate based on config
+func NewAuthMiddleware(config models.Config, secret string) (echo.MiddlewareFunc, error) {
+	switch config.ApiAuth {
+	case models.ApiAuthNone:
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}, nil
+	case "", models.ApiAuthHMAC:
+		if secret == "" {
+			return nil, fmt.Errorf(models.ApiSecretRequiredError)
+		}
+		window := config.ApiAuthWindow
+		if window <= 0 {
+			window = defaultAuthWindow
+		}
+		auth := &hmacAuth{
+			secret: []byte(secret),
+			window: time.Second * time.Duration(window),
+			nonces: make(map[string]time.Time),
+		}
+		return auth.middleware, nil
+	case models.ApiAuthMTLS:
+		return mtlsMiddleware, nil
+	default:
+		return nil, fmt.Errorf(models.WrongApiAuthError)
+	}
+}
+
+//	returns the tls config of the server for mtls authentication, nil if the api is not authenticated with mtls.
+//	client certificates are verified if given, so routes which are not authenticated stay reachable without them
+func NewTLSConfig(config models.Config) (*tls.Config, error) {
+	if config.ApiAuth != models.ApiAuthMTLS {
+		return nil, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	caData, err := ioutil.ReadFile(config.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificate found in client CA file %s", config.TLSClientCAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+//	returns hex of hmac-sha256 of the request with the secret, over method, uri (path and query), timestamp,
+//	nonce and body separated by new lines
+func RequestSignature(secret []byte, method string, uri string, timestamp string, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{method, uri, timestamp, nonce}, "\n")))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//	checks the hmac signature, timestamp and nonce of the request, a nonce is accepted once in the time window
+func (h *hmacAuth) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		timestamp := req.Header.Get(models.TimestampHeader)
+		nonce := req.Header.Get(models.NonceHeader)
+		signature := req.Header.Get(models.SignatureHeader)
+		if timestamp == "" || nonce == "" || signature == "" || len(nonce) > maxNonceLength {
+			return unauthorized(c, models.ApiAuthRequiredError)
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return unauthorized(c, models.ApiTimestampError)
+		}
+		now := time.Now()
+		diff := now.Sub(time.Unix(unix, 0))
+		if diff > h.window || diff < -h.window {
+			return unauthorized(c, models.ApiTimestampError)
+		}
+
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		expected := RequestSignature(h.secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body)
+		if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+			return unauthorized(c, models.ApiSignatureError)
+		}
+
+		if !h.useNonce(nonce, now) {
+			return unauthorized(c, models.ApiNonceReusedError)
+		}
+		return next(c)
+	}
+}
+
+//	records the nonce, returns false if it is used in the time window, expired nonces are removed
+func (h *hmacAuth) useNonce(nonce string, now time.Time) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for item, usedAt := range h.nonces {
+		if now.Sub(usedAt) > 2*h.window {
+			delete(h.nonces, item)
+		}
+	}
+	if _, ok := h.nonces[nonce]; ok {
+		return false
+	}
+	h.nonces[nonce] = now
+	return true
+}
+
+//	accepts requests with a client certificate verified by the client CA
+func mtlsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state := c.Request().TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			return unauthorized(c, models.ApiAuthRequiredError)
+		}
+		return next(c)
+	}
+}
+
+//	logs the rejected request and returns the unauthorized error
+func unauthorized(c echo.Context, message string) error {
+	logging.Warnf("unauthorized request to %s from %s: %s", c.Request().URL.Path, c.RealIP(), message)
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
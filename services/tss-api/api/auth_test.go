@@ -0,0 +1,233 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+)
+
+const testSecret = "secret"
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tss-api-test")
+	if err != nil {
+		panic(err)
+	}
+	err = logger.Init(filepath.Join(dir, "tss.log"), models.Config{LogLevel: logger.ErrorLevelStr}, false)
+	if err != nil {
+		panic(err)
+	}
+	logging = logger.NewSugar("controller")
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestRequestSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		timestamp string
+		nonce     string
+		body      string
+		canonical string
+	}{
+		{
+			name:      "request with body",
+			method:    http.MethodPost,
+			uri:       "/sign",
+			timestamp: "1700000000",
+			nonce:     "n1",
+			body:      `{"crypto":"eddsa"}`,
+			canonical: "POST\n/sign\n1700000000\nn1\n{\"crypto\":\"eddsa\"}",
+		},
+		{
+			name:      "request with query and no body",
+			method:    http.MethodGet,
+			uri:       "/keys?crypto=ecdsa",
+			timestamp: "1700000001",
+			nonce:     "n2",
+			canonical: "GET\n/keys?crypto=ecdsa\n1700000001\nn2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac := hmac.New(sha256.New, []byte(testSecret))
+			mac.Write([]byte(tt.canonical))
+			expected := hex.EncodeToString(mac.Sum(nil))
+			signature := RequestSignature([]byte(testSecret), tt.method, tt.uri, tt.timestamp, tt.nonce, []byte(tt.body))
+			if signature != expected {
+				t.Errorf("signature = %s, want %s", signature, expected)
+			}
+		})
+	}
+}
+
+func TestNewAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    string
+		secret  string
+		wantErr string
+	}{
+		{name: "hmac is the default", auth: "", secret: testSecret},
+		{name: "default needs a secret", auth: "", wantErr: models.ApiSecretRequiredError},
+		{name: "hmac needs a secret", auth: models.ApiAuthHMAC, wantErr: models.ApiSecretRequiredError},
+		{name: "none is set explicitly", auth: models.ApiAuthNone},
+		{name: "mtls", auth: models.ApiAuthMTLS},
+		{name: "wrong mode", auth: "basic", secret: testSecret, wantErr: models.WrongApiAuthError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAuthMiddleware(models.Config{ApiAuth: tt.auth}, tt.secret)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+//	sends a request through the hmac middleware and returns the status code and error message
+func serveSigned(t *testing.T, middleware echo.MiddlewareFunc, headers map[string]string, body string) (int, string) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/sign?crypto=eddsa", strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	err := middleware(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c)
+	if err == nil {
+		return rec.Code, ""
+	}
+	httpErr, ok := err.(*echo.HTTPError)
+	if !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return httpErr.Code, httpErr.Message.(string)
+}
+
+func TestHmacMiddleware(t *testing.T) {
+	body := `{"message":"aa"}`
+	sign := func(timestamp string, nonce string, body string) string {
+		return RequestSignature([]byte(testSecret), http.MethodPost, "/sign?crypto=eddsa", timestamp, nonce, []byte(body))
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-301*time.Second).Unix(), 10)
+	future := strconv.FormatInt(time.Now().Add(301*time.Second).Unix(), 10)
+	nearEdge := strconv.FormatInt(time.Now().Add(-290*time.Second).Unix(), 10)
+	otherSecret := RequestSignature([]byte("other"), http.MethodPost, "/sign?crypto=eddsa", now, "secret", []byte(body))
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		signature string
+		body      string
+		code      int
+		message   string
+	}{
+		{name: "valid", timestamp: now, nonce: "valid", signature: sign(now, "valid", body), body: body, code: http.StatusOK},
+		{
+			name: "upper case signature", timestamp: now, nonce: "upper",
+			signature: strings.ToUpper(sign(now, "upper", body)), body: body, code: http.StatusOK,
+		},
+		{
+			name: "timestamp in the window", timestamp: nearEdge, nonce: "edge",
+			signature: sign(nearEdge, "edge", body), body: body, code: http.StatusOK,
+		},
+		{
+			name: "missing signature", timestamp: now, nonce: "missing", body: body,
+			code: http.StatusUnauthorized, message: models.ApiAuthRequiredError,
+		},
+		{
+			name: "long nonce", timestamp: now, nonce: strings.Repeat("n", maxNonceLength+1),
+			signature: sign(now, strings.Repeat("n", maxNonceLength+1), body), body: body,
+			code: http.StatusUnauthorized, message: models.ApiAuthRequiredError,
+		},
+		{
+			name: "malformed timestamp", timestamp: "now", nonce: "malformed", signature: sign("now", "malformed", body),
+			body: body, code: http.StatusUnauthorized, message: models.ApiTimestampError,
+		},
+		{
+			name: "expired timestamp", timestamp: old, nonce: "old", signature: sign(old, "old", body), body: body,
+			code: http.StatusUnauthorized, message: models.ApiTimestampError,
+		},
+		{
+			name: "future timestamp", timestamp: future, nonce: "future", signature: sign(future, "future", body),
+			body: body, code: http.StatusUnauthorized, message: models.ApiTimestampError,
+		},
+		{
+			name: "tampered body", timestamp: now, nonce: "tampered", signature: sign(now, "tampered", body),
+			body: `{"message":"bb"}`, code: http.StatusUnauthorized, message: models.ApiSignatureError,
+		},
+		{
+			name: "wrong secret", timestamp: now, nonce: "secret", signature: otherSecret, body: body,
+			code: http.StatusUnauthorized, message: models.ApiSignatureError,
+		},
+		{
+			name: "replayed nonce", timestamp: now, nonce: "valid", signature: sign(now, "valid", body), body: body,
+			code: http.StatusUnauthorized, message: models.ApiNonceReusedError,
+		},
+	}
+
+	middleware, err := NewAuthMiddleware(models.Config{ApiAuth: models.ApiAuthHMAC}, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cases run in order, so the nonce of the valid request is replayed by the last case
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				models.TimestampHeader: tt.timestamp,
+				models.NonceHeader:     tt.nonce,
+				models.SignatureHeader: tt.signature,
+			}
+			code, message := serveSigned(t, middleware, headers, tt.body)
+			if code != tt.code || message != tt.message {
+				t.Errorf("response = %d %q, want %d %q", code, message, tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestUseNonce(t *testing.T) {
+	auth := &hmacAuth{window: time.Minute, nonces: make(map[string]time.Time)}
+	now := time.Now()
+	tests := []struct {
+		name  string
+		nonce string
+		at    time.Time
+		want  bool
+	}{
+		{name: "new nonce", nonce: "a", at: now, want: true},
+		{name: "reused nonce", nonce: "a", at: now.Add(time.Minute), want: false},
+		{name: "other nonce", nonce: "b", at: now.Add(time.Minute), want: true},
+		{name: "expired nonce is accepted again", nonce: "a", at: now.Add(3 * time.Minute), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auth.useNonce(tt.nonce, tt.at); got != tt.want {
+				t.Errorf("useNonce(%s) = %v, want %v", tt.nonce, got, tt.want)
+			}
+		})
+	}
+}
@@ -9,14 +9,33 @@ import (
 	"rosen-bridge/tss-api/app/interface"
 	"rosen-bridge/tss-api/logger"
 	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+	"strings"
 )
 
 //	Interface of an app controller
 type TssController interface {
 	Threshold() echo.HandlerFunc
 	Sign() echo.HandlerFunc
+	BatchSign() echo.HandlerFunc
 	Keygen() echo.HandlerFunc
+	Regroup() echo.HandlerFunc
 	Message() echo.HandlerFunc
+	PreParams() echo.HandlerFunc
+	Health() echo.HandlerFunc
+	Ready() echo.HandlerFunc
+	Identity() echo.HandlerFunc
+	PubKey() echo.HandlerFunc
+	Operations() echo.HandlerFunc
+	Operation() echo.HandlerFunc
+	CancelOperation() echo.HandlerFunc
+	Signature() echo.HandlerFunc
+	Callbacks() echo.HandlerFunc
+	RetryCallback() echo.HandlerFunc
+	DeleteCallback() echo.HandlerFunc
+	Keys() echo.HandlerFunc
+	CheckKeys() echo.HandlerFunc
+	ArchiveKey() echo.HandlerFunc
 	Validate(interface{}) error
 }
 
@@ -26,7 +45,12 @@ type tssController struct {
 }
 
 type response struct {
-	Message string `json:"message"`
+	Message       string           `json:"message"`
+	OperationId   string           `json:"operationId,omitempty"`
+	SignatureHash string           `json:"signatureHash,omitempty"`
+	Signature     *models.SignData `json:"signature,omitempty"`
+	QueuePosition int              `json:"queuePosition,omitempty"`
+	CallbackError string           `json:"callbackError,omitempty"`
 }
 
 var logging *zap.SugaredLogger
@@ -40,13 +64,32 @@ func NewTssController(rosenTss _interface.RosenTss) TssController {
 	}
 }
 
-//	check if there is any common operation between forbidden and running ones.
-func (tssController *tssController) checkKeygenOperation(crypto string) error {
-	forbiddenOperations := []string{crypto + "Sign", crypto + "Regroup"}
-	operations := tssController.rosenTss.GetKeygenOperations()
-	for _, operation := range operations {
+//	returns class names of running operations of the key.
+func (tssController *tssController) runningOperations(keyId string) []string {
+	var operations []string
+	for _, operation := range tssController.rosenTss.GetKeygenOperations() {
+		if operation.GetKeyId() == keyId {
+			operations = append(operations, operation.GetClassName())
+		}
+	}
+	for _, operation := range tssController.rosenTss.GetSignOperations() {
+		if operation.GetKeyId() == keyId {
+			operations = append(operations, operation.GetClassName())
+		}
+	}
+	for _, operation := range tssController.rosenTss.GetRegroupOperations() {
+		if operation.GetKeyId() == keyId {
+			operations = append(operations, operation.GetClassName())
+		}
+	}
+	return operations
+}
+
+//	check if there is any common operation between forbidden and running ones of the key.
+func (tssController *tssController) checkForbiddenOperations(forbiddenOperations []string, keyId string) error {
+	for _, operation := range tssController.runningOperations(keyId) {
 		for _, forbidden := range forbiddenOperations {
-			if operation.GetClassName() == forbidden {
+			if operation == forbidden {
 				return fmt.Errorf("%s "+models.OperationIsRunningError, forbidden)
 			}
 		}
@@ -54,6 +97,12 @@ func (tssController *tssController) checkKeygenOperation(crypto string) error {
 	return nil
 }
 
+//	check if there is any common operation between forbidden and running ones.
+func (tssController *tssController) checkKeygenOperation(crypto string, keyId string) error {
+	forbiddenOperations := []string{crypto + "Sign", crypto + "Regroup"}
+	return tssController.checkForbiddenOperations(forbiddenOperations, keyId)
+}
+
 func (tssController *tssController) Validate(i interface{}) error {
 	if err := tssController.validator.Struct(i); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
@@ -62,26 +111,26 @@ func (tssController *tssController) Validate(i interface{}) error {
 }
 
 //	check if there is any common operation between forbidden and running ones.
-func (tssController *tssController) checkSignOperation(crypto string) error {
+func (tssController *tssController) checkSignOperation(crypto string, keyId string) error {
 	forbiddenOperations := []string{crypto + "Keygen", crypto + "Regroup"}
-	operations := tssController.rosenTss.GetSignOperations()
-	for _, operation := range operations {
-		for _, forbidden := range forbiddenOperations {
-			if operation.GetClassName() == forbidden {
-				return fmt.Errorf("%s "+models.OperationIsRunningError, forbidden)
-			}
-		}
-	}
-	return nil
+	return tssController.checkForbiddenOperations(forbiddenOperations, keyId)
 }
 
 //	check if there is any common operation between forbidden and running ones.
-func (tssController *tssController) checkOperation(operationName string, crypto string) error {
+func (tssController *tssController) checkRegroupOperation(crypto string, keyId string) error {
+	forbiddenOperations := []string{crypto + "Keygen", crypto + "Sign"}
+	return tssController.checkForbiddenOperations(forbiddenOperations, keyId)
+}
+
+//	check if there is any common operation between forbidden and running ones.
+func (tssController *tssController) checkOperation(operationName string, crypto string, keyId string) error {
 	switch operationName {
 	case "keygen":
-		return tssController.checkKeygenOperation(crypto)
+		return tssController.checkKeygenOperation(crypto, keyId)
 	case "sign":
-		return tssController.checkSignOperation(crypto)
+		return tssController.checkSignOperation(crypto, keyId)
+	case "regroup":
+		return tssController.checkRegroupOperation(crypto, keyId)
 	default:
 		return fmt.Errorf(models.WrongOperationError)
 	}
@@ -99,24 +148,31 @@ func (tssController *tssController) Keygen() echo.HandlerFunc {
 			return err
 		}
 		logging.Debugf("keygen controller called with data: {%v}", data)
-		err = tssController.checkOperation("keygen", data.Crypto)
+		data.KeyId, err = utils.CheckKeyId(data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		err = tssController.checkOperation("keygen", data.Crypto, data.KeyId)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusConflict, err.Error())
 		}
-		err = tssController.rosenTss.StartNewKeygen(data)
+		operationId, err := tssController.rosenTss.StartNewKeygen(data)
 		if err != nil {
 			switch err.Error() {
 			case models.DuplicatedMessageIdError:
 				return echo.NewHTTPError(http.StatusConflict, err.Error())
 			case models.KeygenFileExistError, models.WrongCryptoProtocolError:
 				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case models.PreParamsNotReadyError, models.PreParamsNotConfiguredError:
+				return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
 			default:
 				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 			}
 		}
 		return c.JSON(
 			http.StatusOK, response{
-				Message: "ok",
+				Message:     "ok",
+				OperationId: operationId,
 			},
 		)
 	}
@@ -134,15 +190,39 @@ func (tssController *tssController) Sign() echo.HandlerFunc {
 			return err
 		}
 		logging.Debugf("sign controller called with data: {%v}", data)
-		err = tssController.checkOperation("sign", data.Crypto)
+		data.KeyId, err = utils.CheckKeyId(data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		record, err := tssController.rosenTss.CachedSignature(data)
+		if err == nil {
+			resp := response{
+				Message:       "ok",
+				SignatureHash: record.Hash,
+				Signature:     &record.SignData,
+			}
+			// the signature is known, a failed callback does not fail the request
+			err = tssController.rosenTss.ResendSignature(record, data.CallBackUrl)
+			if err != nil {
+				logging.Warnf("unable to send cached signature %s to callback, err: %+v", record.Hash, err)
+				resp.CallbackError = err.Error()
+			}
+			return c.JSON(http.StatusOK, resp)
+		}
+		if err.Error() != models.SignatureNotFoundError {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		err = tssController.checkOperation("sign", data.Crypto, data.KeyId)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusConflict, err.Error())
 		}
-		err = tssController.rosenTss.StartNewSign(data)
+		operationId, err := tssController.rosenTss.StartNewSign(data)
 		if err != nil {
 			switch err.Error() {
 			case models.DuplicatedMessageIdError:
 				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case models.SignQueueFullError:
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
 			case
 				models.ECDSANoKeygenDataFoundError,
 				models.WrongDerivationPathError,
@@ -156,7 +236,108 @@ func (tssController *tssController) Sign() echo.HandlerFunc {
 
 		return c.JSON(
 			http.StatusOK, response{
-				Message: "ok",
+				Message:       "ok",
+				OperationId:   operationId,
+				SignatureHash: utils.SignatureHash(data.Crypto, data.KeyId, data.Message, data.ChainCode, data.DerivationPath),
+				QueuePosition: tssController.rosenTss.GetSignQueue().Position(operationId),
+			},
+		)
+	}
+}
+
+//	returns echo handler, starting new sign process of a batch of messages
+func (tssController *tssController) BatchSign() echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		data := models.BatchSignMessage{}
+
+		if err = c.Bind(&data); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err = c.Validate(&data); err != nil {
+			return err
+		}
+		logging.Debugf("batch sign controller called with data: {%v}", data)
+		data.KeyId, err = utils.CheckKeyId(data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		err = tssController.checkOperation("sign", data.Crypto, data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		operationId, err := tssController.rosenTss.StartNewBatchSign(data)
+		if err != nil {
+			switch err.Error() {
+			case models.DuplicatedMessageIdError:
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case models.SignQueueFullError:
+				return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+			case
+				models.EmptyBatchError,
+				models.BatchTooLargeError,
+				models.DuplicatedBatchMessageError,
+				models.ECDSANoKeygenDataFoundError,
+				models.WrongDerivationPathError,
+				models.EDDSANoKeygenDataFoundError,
+				models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+
+		return c.JSON(
+			http.StatusOK, response{
+				Message:     "ok",
+				OperationId: operationId,
+			},
+		)
+	}
+}
+
+//	returns echo handler, starting new regroup process.
+func (tssController *tssController) Regroup() echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		data := models.RegroupMessage{}
+
+		if err = c.Bind(&data); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err = c.Validate(&data); err != nil {
+			return err
+		}
+		logging.Debugf("regroup controller called with data: {%v}", data)
+		data.KeyId, err = utils.CheckKeyId(data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		err = tssController.checkOperation("regroup", data.Crypto, data.KeyId)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		operationId, err := tssController.rosenTss.StartNewRegroup(data)
+		if err != nil {
+			switch err.Error() {
+			case models.DuplicatedMessageIdError:
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			case
+				models.ECDSANoKeygenDataFoundError,
+				models.EDDSANoKeygenDataFoundError,
+				models.NotInRegroupCommitteeError,
+				models.WrongRegroupPeersError,
+				models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case models.PreParamsNotReadyError, models.PreParamsNotConfiguredError:
+				return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+
+		return c.JSON(
+			http.StatusOK, response{
+				Message:     "ok",
+				OperationId: operationId,
 			},
 		)
 	}
@@ -189,8 +370,12 @@ func (tssController *tssController) Threshold() echo.HandlerFunc {
 		if crypto == "" {
 			return echo.NewHTTPError(http.StatusBadRequest, models.InvalidCryptoFoundError)
 		}
+		keyId, err := utils.CheckKeyId(c.QueryParam("keyId"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 
-		meta, err := tssController.rosenTss.GetMetaData(crypto)
+		meta, err := tssController.rosenTss.GetMetaData(crypto, keyId)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
@@ -198,3 +383,257 @@ func (tssController *tssController) Threshold() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, res)
 	}
 }
+
+//	returns echo handler, get status of ecdsa pre-params
+func (tssController *tssController) PreParams() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		preParams := tssController.rosenTss.GetPreParams()
+		if preParams == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, models.PreParamsNotConfiguredError)
+		}
+		return c.JSON(http.StatusOK, preParams.Status())
+	}
+}
+
+//	returns echo handler, reports the process is alive
+func (tssController *tssController) Health() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(
+			http.StatusOK, response{
+				Message: "ok",
+			},
+		)
+	}
+}
+
+//	returns echo handler, reports readiness of the app, status is 503 if it can not serve operations
+func (tssController *tssController) Ready() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		readiness := tssController.rosenTss.GetReadiness()
+		if !readiness.Ready {
+			return c.JSON(http.StatusServiceUnavailable, readiness)
+		}
+		return c.JSON(http.StatusOK, readiness)
+	}
+}
+
+//	returns echo handler, get the identity of the peer to register it in peers file of other peers
+func (tssController *tssController) Identity() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, tssController.rosenTss.GetIdentity())
+	}
+}
+
+//	returns echo handler, get derived public key and extended public key for chain code and derivation path
+func (tssController *tssController) PubKey() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		crypto := c.QueryParam("crypto")
+		if crypto == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, models.InvalidCryptoFoundError)
+		}
+		// the chain code is serialized in the extended public key, so it must be 32 bytes
+		chainCode := c.QueryParam("chainCode")
+		if len(chainCode) != models.ChainCodeSize {
+			return echo.NewHTTPError(http.StatusBadRequest, models.InvalidChainCodeError)
+		}
+		path, err := utils.ParseDerivationPath(c.QueryParam("path"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		keyId, err := utils.CheckKeyId(c.QueryParam("keyId"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		pubKey, err := tssController.rosenTss.GetDerivedPubKey(crypto, keyId, chainCode, path)
+		if err != nil {
+			switch err.Error() {
+			case
+				models.ECDSANoKeygenDataFoundError,
+				models.EDDSANoKeygenDataFoundError,
+				models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		return c.JSON(http.StatusOK, pubKey)
+	}
+}
+
+//	returns echo handler, list of operations
+func (tssController *tssController) Operations() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		operations := tssController.rosenTss.GetRegistry().List()
+		for i := range operations {
+			operations[i].QueuePosition = tssController.rosenTss.GetSignQueue().Position(operations[i].Id)
+		}
+		return c.JSON(http.StatusOK, operations)
+	}
+}
+
+//	returns echo handler, get an operation by id
+func (tssController *tssController) Operation() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		operation, err := tssController.rosenTss.GetRegistry().Get(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		operation.QueuePosition = tssController.rosenTss.GetSignQueue().Position(operation.Id)
+		return c.JSON(http.StatusOK, operation)
+	}
+}
+
+//	returns echo handler, cancel a running operation by id
+func (tssController *tssController) CancelOperation() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Param("id")
+		err := tssController.rosenTss.GetRegistry().Cancel(id)
+		if err != nil {
+			switch err.Error() {
+			case models.OperationNotFoundError:
+				return echo.NewHTTPError(http.StatusNotFound, err.Error())
+			case models.OperationFinishedError:
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		logging.Infof("operation %s cancelled", id)
+		return c.JSON(
+			http.StatusOK, response{
+				Message:     "ok",
+				OperationId: id,
+			},
+		)
+	}
+}
+
+//	returns echo handler, get a cached signature by the hash of its sign request
+func (tssController *tssController) Signature() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		record, err := tssController.rosenTss.GetSignature(c.Param("hash"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(http.StatusOK, record)
+	}
+}
+
+//	returns echo handler, list of callbacks waiting in the outbox
+func (tssController *tssController) Callbacks() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		callbackOutbox := tssController.rosenTss.GetOutbox()
+		if callbackOutbox == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, models.OutboxNotConfiguredError)
+		}
+		return c.JSON(http.StatusOK, callbackOutbox.List())
+	}
+}
+
+//	returns echo handler, deliver a pending callback by id now
+func (tssController *tssController) RetryCallback() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		callbackOutbox := tssController.rosenTss.GetOutbox()
+		if callbackOutbox == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, models.OutboxNotConfiguredError)
+		}
+		id := c.Param("id")
+		err := callbackOutbox.Retry(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(
+			http.StatusOK, response{
+				Message: "ok",
+			},
+		)
+	}
+}
+
+//	returns echo handler, drop a pending callback by id
+func (tssController *tssController) DeleteCallback() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		callbackOutbox := tssController.rosenTss.GetOutbox()
+		if callbackOutbox == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable, models.OutboxNotConfiguredError)
+		}
+		id := c.Param("id")
+		err := callbackOutbox.Delete(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(
+			http.StatusOK, response{
+				Message: "ok",
+			},
+		)
+	}
+}
+
+//	returns echo handler, list of stored keys of a crypto
+func (tssController *tssController) Keys() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		crypto := c.QueryParam("crypto")
+		if crypto == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, models.InvalidCryptoFoundError)
+		}
+		keys, err := tssController.rosenTss.GetKeys(crypto)
+		if err != nil {
+			switch err.Error() {
+			case models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		return c.JSON(http.StatusOK, keys)
+	}
+}
+
+//	returns echo handler, checks integrity of the stored keys
+func (tssController *tssController) CheckKeys() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		checks, err := tssController.rosenTss.CheckKeys(c.QueryParam("crypto"))
+		if err != nil {
+			switch err.Error() {
+			case models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			default:
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		return c.JSON(http.StatusOK, checks)
+	}
+}
+
+//	returns echo handler, archive a stored key
+func (tssController *tssController) ArchiveKey() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		crypto := c.Param("crypto")
+		keyId, err := utils.CheckKeyId(c.Param("keyId"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		err = tssController.rosenTss.ArchiveKey(crypto, keyId)
+		if err != nil {
+			switch err.Error() {
+			case models.WrongCryptoProtocolError:
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			case models.KeyNotFoundError:
+				return echo.NewHTTPError(http.StatusNotFound, err.Error())
+			default:
+				if strings.HasSuffix(err.Error(), models.OperationIsRunningError) {
+					return echo.NewHTTPError(http.StatusConflict, err.Error())
+				}
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		logging.Infof("%s key %s archived", crypto, keyId)
+		return c.JSON(
+			http.StatusOK, response{
+				Message: "ok",
+			},
+		)
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"github.com/brpaz/echozap"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"rosen-bridge/tss-api/logger"
 )
 
 // InitRouting Initialize Router
-func InitRouting(e *echo.Echo, tssController TssController) {
+func InitRouting(e *echo.Echo, tssController TssController, auth echo.MiddlewareFunc) {
 	// Middleware
 	zapLogger := logger.NewLogger().Named("tss/http")
 
@@ -16,8 +17,26 @@ func InitRouting(e *echo.Echo, tssController TssController) {
 	e.Use(echozap.ZapLogger(zapLogger))
 	e.Use(middleware.Recover())
 
-	e.GET("/threshold", tssController.Threshold())
-	e.POST("/sign", tssController.Sign())
-	e.POST("/keygen", tssController.Keygen())
+	e.GET("/health", tssController.Health())
+	e.GET("/ready", tssController.Ready())
+	e.GET("/identity", tssController.Identity())
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	e.GET("/threshold", tssController.Threshold(), auth)
+	e.GET("/preParams", tssController.PreParams(), auth)
+	e.GET("/pubkey", tssController.PubKey(), auth)
+	e.GET("/operations", tssController.Operations(), auth)
+	e.GET("/operations/:id", tssController.Operation(), auth)
+	e.DELETE("/operations/:id", tssController.CancelOperation(), auth)
+	e.GET("/signatures/:hash", tssController.Signature(), auth)
+	e.GET("/callbacks", tssController.Callbacks(), auth)
+	e.POST("/callbacks/:id/retry", tssController.RetryCallback(), auth)
+	e.DELETE("/callbacks/:id", tssController.DeleteCallback(), auth)
+	e.GET("/keys", tssController.Keys(), auth)
+	e.GET("/keys/check", tssController.CheckKeys(), auth)
+	e.DELETE("/keys/:crypto/:keyId", tssController.ArchiveKey(), auth)
+	e.POST("/sign", tssController.Sign(), auth)
+	e.POST("/sign/batch", tssController.BatchSign(), auth)
+	e.POST("/keygen", tssController.Keygen(), auth)
+	e.POST("/regroup", tssController.Regroup(), auth)
 	e.POST("/message", tssController.Message())
 }
@@ -0,0 +1,314 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	defaultSignBatchMaxSize = 32
+)
+
+//	a running sign batch, results of its items are collected until all of them are finished
+type signBatch struct {
+	lock         sync.Mutex
+	messageId    string
+	operationId  string
+	message      models.BatchSignMessage
+	operationIds []string
+	items        []models.SignData
+	pending      int
+}
+
+//	returns index of the item of the message
+func (b *signBatch) index(message string) int {
+	for i, item := range b.message.Items {
+		if strings.EqualFold(item.Message, message) {
+			return i
+		}
+	}
+	return -1
+}
+
+//	sets the sign operation of the item
+func (b *signBatch) setOperationId(index int, operationId string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.operationIds[index] = operationId
+}
+
+//	returns sign operations of the started items
+func (b *signBatch) operations() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	operationIds := make([]string, 0, len(b.operationIds))
+	for _, operationId := range b.operationIds {
+		if operationId != "" {
+			operationIds = append(operationIds, operationId)
+		}
+	}
+	return operationIds
+}
+
+//	sets the result of the item, the trust key is only sent with the batch
+func (b *signBatch) setItem(index int, data models.SignData) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	data.TrustKey = ""
+	b.items[index] = data
+}
+
+//	marks one item as finished, returns true if all items are finished
+func (b *signBatch) finishItem() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.pending--
+	return b.pending == 0
+}
+
+//	returns results of the items, the status is success if all items are signed, fail if none is signed and
+//	partial otherwise
+func (b *signBatch) data() (models.BatchSignData, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	items := make([]models.SignData, len(b.items))
+	copy(items, b.items)
+	signed := 0
+	for _, item := range items {
+		if item.Status == "success" {
+			signed++
+		}
+	}
+	status := "partial"
+	switch signed {
+	case len(items):
+		status = "success"
+	case 0:
+		status = "fail"
+	}
+	return models.BatchSignData{
+		BatchId: b.operationId,
+		KeyId:   b.message.KeyId,
+		Items:   items,
+		Status:  status,
+	}, len(items) - signed
+}
+
+// StartNewBatchSign starts signing messages of the batch with the same peers, each message is signed by its own sign
+// operation and one callback with results of all messages is sent when they are finished.
+func (r *rosenTss) StartNewBatchSign(batchMessage models.BatchSignMessage) (string, error) {
+	logging.Infof("Starting New batch sign process of %d messages", len(batchMessage.Items))
+	keyId, err := utils.CheckKeyId(batchMessage.KeyId)
+	if err != nil {
+		return "", err
+	}
+	batchMessage.KeyId = keyId
+
+	messageId, err := r.batchMessageId(batchMessage)
+	if err != nil {
+		return "", err
+	}
+	// the batch is rejected as a whole if its messages do not fit in the sign queue, places of all messages are
+	// reserved at once, so concurrent signs can not take them while the items are started
+	if batchMessage.Crypto != models.ECDSA && batchMessage.Crypto != models.EDDSA {
+		return "", fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	err = r.signQueue.Reserve(batchMessage.Crypto, len(batchMessage.Items))
+	if err != nil {
+		return "", err
+	}
+	batch := &signBatch{
+		messageId:    messageId,
+		message:      batchMessage,
+		operationIds: make([]string, len(batchMessage.Items)),
+		items:        make([]models.SignData, len(batchMessage.Items)),
+		// the batch is not finished before all of its items are started
+		pending: len(batchMessage.Items) + 1,
+	}
+	for i, item := range batchMessage.Items {
+		batch.items[i] = models.SignData{
+			Message:        item.Message,
+			KeyId:          keyId,
+			DerivationPath: item.DerivationPath,
+			Status:         "pending",
+		}
+	}
+	err = r.manager.addBatch(batch)
+	if err != nil {
+		r.signQueue.Release(batchMessage.Crypto, len(batchMessage.Items))
+		return "", err
+	}
+	batch.operationId = r.registry.Add("batch-sign", batchMessage.Crypto, keyId, messageId)
+	data, _ := batch.data()
+	r.registry.SetResult(messageId, data)
+	r.registry.SetState(batch.operationId, models.OperationWaitingForPeers)
+
+	started := 0
+	var startErr error
+	for i, item := range batchMessage.Items {
+		signMessage := models.SignMessage{
+			Crypto:           batchMessage.Crypto,
+			Message:          item.Message,
+			CallBackUrl:      batchMessage.CallBackUrl,
+			Peers:            batchMessage.Peers,
+			OperationTimeout: batchMessage.OperationTimeout,
+			ChainCode:        batchMessage.ChainCode,
+			DerivationPath:   item.DerivationPath,
+			KeyId:            keyId,
+			Priority:         batchMessage.Priority,
+			QueueTimeout:     batchMessage.QueueTimeout,
+			BatchId:          messageId,
+		}
+		hash := utils.SignatureHash(signMessage.Crypto, keyId, signMessage.Message, signMessage.ChainCode, signMessage.DerivationPath)
+		if record, err := r.signatures.Get(hash); err == nil {
+			logging.Infof("message %s of the batch is signed already, using the cached signature", item.Message)
+			r.signQueue.Release(batchMessage.Crypto, 1)
+			started++
+			r.finishBatchItem(batch, i, record.SignData)
+			continue
+		}
+		// the sign registers its operation in the batch before it is started
+		_, err := r.StartNewSign(signMessage)
+		if err != nil {
+			logging.Errorf("unable to start sign of message %s of the batch, err: %+v", item.Message, err)
+			r.signQueue.Release(batchMessage.Crypto, 1)
+			startErr = err
+			r.finishBatchItem(batch, i, r.signFailure(signMessage, "", err))
+			continue
+		}
+		started++
+	}
+
+	if started == 0 {
+		// no message is being signed, the request is rejected instead of sending a callback
+		r.manager.removeBatch(messageId)
+		r.registry.Finish(batch.operationId, startErr)
+		return "", startErr
+	}
+	go r.watchBatch(batch)
+	if batch.finishItem() {
+		r.finishBatch(batch)
+	}
+	return batch.operationId, nil
+}
+
+//	validates messages of the batch and returns the messageId of the batch, based on hash of its messages
+func (r *rosenTss) batchMessageId(batchMessage models.BatchSignMessage) (string, error) {
+	if len(batchMessage.Items) == 0 {
+		return "", fmt.Errorf(models.EmptyBatchError)
+	}
+	maxSize := r.Config.SignBatchMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultSignBatchMaxSize
+	}
+	if len(batchMessage.Items) > maxSize {
+		return "", fmt.Errorf(models.BatchTooLargeError)
+	}
+
+	// messages of the batch are signed in separate channels, so a message can not be repeated
+	messages := make([]string, 0, len(batchMessage.Items))
+	seen := make(map[string]bool)
+	for _, item := range batchMessage.Items {
+		message := strings.ToLower(item.Message)
+		if seen[message] {
+			return "", fmt.Errorf(models.DuplicatedBatchMessageError)
+		}
+		seen[message] = true
+		messages = append(messages, message)
+	}
+	batchBytes := blake2b.Sum256([]byte(strings.Join(messages, "|")))
+	return utils.KeyMessageId(fmt.Sprintf("%sBatch%s", batchMessage.Crypto, utils.HexEncoder(batchBytes[:])), batchMessage.KeyId), nil
+}
+
+//	records the sign operation of an item of a batch, so it is cancelled with the batch
+func (r *rosenTss) setBatchOperationId(signMessage models.SignMessage, operationId string) {
+	batch, ok := r.manager.getBatch(signMessage.BatchId)
+	if !ok {
+		logging.Warnf("batch of message %s is not found", signMessage.Message)
+		return
+	}
+	index := batch.index(signMessage.Message)
+	if index == -1 {
+		logging.Warnf("message %s is not found in its batch", signMessage.Message)
+		return
+	}
+	batch.setOperationId(index, operationId)
+}
+
+//	records the result of a finished sign operation of a batch
+func (r *rosenTss) signBatchItemFinished(signMessage models.SignMessage, operationId string, err error) {
+	batch, ok := r.manager.getBatch(signMessage.BatchId)
+	if !ok {
+		logging.Warnf("batch of message %s is not found", signMessage.Message)
+		return
+	}
+	index := batch.index(signMessage.Message)
+	if index == -1 {
+		logging.Warnf("message %s is not found in its batch", signMessage.Message)
+		return
+	}
+
+	if err != nil {
+		r.finishBatchItem(batch, index, r.signFailure(signMessage, operationId, err))
+		return
+	}
+	operation, err := r.registry.Get(operationId)
+	if err != nil {
+		r.finishBatchItem(batch, index, r.signFailure(signMessage, operationId, err))
+		return
+	}
+	signData, ok := operation.Result.(models.SignData)
+	if !ok {
+		r.finishBatchItem(batch, index, r.signFailure(signMessage, operationId, fmt.Errorf(models.SignatureNotFoundError)))
+		return
+	}
+	r.finishBatchItem(batch, index, signData)
+}
+
+//	sets the result of the item, the batch is finished with its last item
+func (r *rosenTss) finishBatchItem(batch *signBatch, index int, data models.SignData) {
+	batch.setItem(index, data)
+	result, _ := batch.data()
+	r.registry.SetResult(batch.messageId, result)
+	if batch.finishItem() {
+		r.finishBatch(batch)
+	}
+}
+
+//	finishes the batch operation and sends results of all items to the callback of the batch
+func (r *rosenTss) finishBatch(batch *signBatch) {
+	r.manager.removeBatch(batch.messageId)
+	data, failed := batch.data()
+	r.registry.SetResult(batch.messageId, data)
+	var err error
+	if failed > 0 {
+		err = fmt.Errorf("%d of %d messages of the batch are not signed", failed, len(data.Items))
+	}
+	r.registry.Finish(batch.operationId, err)
+	logging.Infof("end of %s batch sign with status %s", batch.message.Crypto, data.Status)
+
+	// the stored result has no trust key, it is only set on the copy sent to the callback
+	callbackData := data
+	callbackData.TrustKey = r.GetCallbackTrustKey()
+	err = r.CallBack(batch.message.CallBackUrl, callbackData)
+	if err != nil {
+		logging.Error(err)
+	}
+}
+
+//	cancels running items of the batch when the batch is cancelled
+func (r *rosenTss) watchBatch(batch *signBatch) {
+	select {
+	case <-r.registry.Cancelled(batch.operationId):
+		for _, operationId := range batch.operations() {
+			// finished items can not be cancelled
+			_ = r.registry.Cancel(operationId)
+		}
+	case <-r.registry.Done(batch.operationId):
+	}
+}
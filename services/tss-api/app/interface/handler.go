@@ -15,6 +15,10 @@ type SignOperationHandler struct {
 	SignOperation
 }
 
+type RegroupOperationHandler struct {
+	RegroupOperation
+}
+
 //	handles gossip message from party to party(s)
 func (o *KeygenOperationHandler) PartyMessageHandler(partyMsg tss.Message) (string, error) {
 	msgBytes, _, err := partyMsg.WireBytes()
@@ -82,3 +86,37 @@ func (o *SignOperationHandler) SharedPartyUpdater(party tss.Party, msg models.Pa
 	}
 	return nil
 }
+
+//	handles gossip message from party to party(s)
+func (o *RegroupOperationHandler) PartyMessageHandler(partyMsg tss.Message) (string, error) {
+	msgBytes, _, err := partyMsg.WireBytes()
+	if err != nil {
+		return "", err
+	}
+	partyMessage := models.PartyMessage{
+		Message:                 msgBytes,
+		IsBroadcast:             partyMsg.IsBroadcast(),
+		GetFrom:                 partyMsg.GetFrom(),
+		GetTo:                   partyMsg.GetTo(),
+		IsToOldCommittee:        partyMsg.IsToOldCommittee(),
+		IsToOldAndNewCommittees: partyMsg.IsToOldAndNewCommittees(),
+	}
+
+	partyMessageBytes, err := json.Marshal(partyMessage)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(partyMessageBytes), nil
+}
+
+//	this is used to update party
+func (o *RegroupOperationHandler) SharedPartyUpdater(party tss.Party, msg models.PartyMessage) error {
+	// do not send a message from this party back to itself
+	if party.PartyID().KeyInt().Cmp(msg.GetFrom.KeyInt()) == 0 {
+		return nil
+	}
+	if _, err := party.UpdateFromBytes(msg.Message, msg.GetFrom, msg.IsBroadcast); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package _interface
 
 import (
+	"rosen-bridge/tss-api/identity"
 	"rosen-bridge/tss-api/models"
 	"rosen-bridge/tss-api/network"
+	"rosen-bridge/tss-api/operations"
+	"rosen-bridge/tss-api/outbox"
+	"rosen-bridge/tss-api/preparams"
+	"rosen-bridge/tss-api/queue"
 	"rosen-bridge/tss-api/storage"
 )
 
@@ -11,6 +16,7 @@ type KeygenOperation interface {
 	Init(RosenTss, []string) error
 	StartAction(RosenTss, chan models.GossipMessage, chan error) error
 	GetClassName() string
+	GetKeyId() string
 }
 
 //	(sign protocol)
@@ -18,28 +24,64 @@ type SignOperation interface {
 	Init(RosenTss, []models.Peer) error
 	StartAction(RosenTss, chan models.GossipMessage, chan error) error
 	GetClassName() string
+	GetKeyId() string
+}
+
+//	(regroup protocol)
+type RegroupOperation interface {
+	Init(RosenTss, []models.Peer, []string) error
+	StartAction(RosenTss, chan models.GossipMessage, chan error) error
+	GetClassName() string
+	GetKeyId() string
 }
 
 //	Interface of an app
 type RosenTss interface {
-	StartNewKeygen(models.KeygenMessage) error
-	StartNewSign(models.SignMessage) error
+	StartNewKeygen(models.KeygenMessage) (string, error)
+	StartNewSign(models.SignMessage) (string, error)
+	StartNewBatchSign(models.BatchSignMessage) (string, error)
+	CachedSignature(models.SignMessage) (models.SignatureRecord, error)
+	ResendSignature(record models.SignatureRecord, callBackUrl string) error
+	GetSignature(hash string) (models.SignatureRecord, error)
+	StartNewRegroup(models.RegroupMessage) (string, error)
 	MessageHandler(models.Message) error
+	Publish(models.GossipMessage) error
+	CallBack(url string, data interface{}) error
 
 	GetStorage() storage.Storage
 	GetConnection() network.Connection
+	GetRegistry() operations.Registry
+	GetSignQueue() queue.Queue
+
+	SetPreParams(preparams.PreParams)
+	GetPreParams() preparams.PreParams
+
+	SetOutbox(outbox.Outbox)
+	GetOutbox() outbox.Outbox
+
+	SetIdentity(identity.Identity)
+	GetIdentity() models.PeerIdentity
+
+	SetMetaData(data models.MetaData, crypto string, keyId string) error
+	GetMetaData(crypto string, keyId string) (models.MetaData, error)
 
-	SetMetaData(data models.MetaData, crypto string) error
-	GetMetaData(crypto string) (models.MetaData, error)
+	GetDerivedPubKey(crypto string, keyId string, chainCode string, path []uint32) (models.PubKeyData, error)
+	GetKeys(crypto string) ([]models.KeyInfo, error)
+	ArchiveKey(crypto string, keyId string) error
+	CheckKeys(crypto string) ([]models.KeyCheck, error)
 
 	SetPeerHome(string) error
 	GetPeerHome() string
 
 	GetKeygenOperations() map[string]KeygenOperation
 	GetSignOperations() map[string]SignOperation
+	GetRegroupOperations() map[string]RegroupOperation
 
+	Subscribe(projectUrl string) error
 	SetP2pId() error
 	GetP2pId() string
+	GetReadiness() models.Readiness
 	GetConfig() models.Config
 	GetTrustKey() string
+	GetCallbackTrustKey() string
 }
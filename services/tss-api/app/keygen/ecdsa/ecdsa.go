@@ -13,10 +13,11 @@ import (
 	"rosen-bridge/tss-api/logger"
 	"rosen-bridge/tss-api/models"
 	"rosen-bridge/tss-api/utils"
-	"time"
+	"sync"
 )
 
 var logging *zap.SugaredLogger
+var loggingOnce sync.Once
 
 var ecdsaHandler handler
 
@@ -30,7 +31,7 @@ func (s *operationECDSAKeygen) Init(rosenTss _interface.RosenTss, peers []string
 		Threshold:  s.KeygenMessage.Threshold,
 	}
 
-	err := rosenTss.SetMetaData(meta, models.ECDSA)
+	err := rosenTss.SetMetaData(meta, models.ECDSA, s.KeygenMessage.KeyId)
 	if err != nil {
 		return err
 	}
@@ -54,72 +55,103 @@ func (s *operationECDSAKeygen) Init(rosenTss _interface.RosenTss, peers []string
 
 	s.Logger.Infof("local PartyId: %+v", s.LocalTssData.PartyID)
 
+	// pre-params are taken out of the pool last, so they are not lost if the initiation fails
+	if rosenTss.GetPreParams() == nil {
+		return fmt.Errorf(models.PreParamsNotConfiguredError)
+	}
+	preParams, err := rosenTss.GetPreParams().Pop()
+	if err != nil {
+		return err
+	}
+	s.preParams = preParams
+
 	return nil
 }
 
 //	- creates end and out channel for party,
 //	- calls StartParty function of protocol
 //	- handles end channel and out channel in a go routine
-func (s *operationECDSAKeygen) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error) {
+func (s *operationECDSAKeygen) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error, done chan struct{}) error {
 	s.Logger.Info("creating and starting party")
 
 	outCh := make(chan tss.Message, len(s.LocalTssData.PartyIds))
 	endCh := make(chan *ecdsaKeygen.LocalPartySaveData, len(s.LocalTssData.PartyIds))
 
-	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA)
+	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA, s.KeygenMessage.KeyId)
 	if err != nil {
 		s.Logger.Errorf("there was an error in getting metadata: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
-	err = s.StartParty(&s.LocalTssData, ecdsaMetaData.Threshold, outCh, endCh)
+	err = s.StartParty(&s.LocalTssData, ecdsaMetaData.Threshold, s.preParams, outCh, endCh)
 	if err != nil {
 		s.Logger.Errorf("there was an error in starting party: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
 	s.Logger.Debugf("party info: %v ", s.LocalTssData.Party)
 	go func() {
-		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh)
+		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh, done)
+		select {
+		case <-done:
+			// the action is already stopped (cancelled or timed out)
+			return
+		default:
+		}
 		if err != nil {
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		}
 		if !result {
 			err = fmt.Errorf("close channel")
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		} else {
 			s.Logger.Infof("end party successfully")
-			statusCh <- true
+			select {
+			case statusCh <- true:
+			case <-done:
+			}
 			return
 		}
 	}()
+	return nil
 }
 
 //	- reads new gossip messages from channel and handle it by calling related function in a go routine.
 func (s *operationECDSAKeygen) StartAction(rosenTss _interface.RosenTss, messageCh chan models.GossipMessage, errorCh chan error) error {
 
-	partyStarted := false
 	statusCh := make(chan bool)
+	// done is closed when the action returns, so goroutines of the party stop instead of blocking
+	done := make(chan struct{})
+	defer close(done)
+
+	// the party is created before any message is handled, so goroutines of the messages only read it
+	err := s.CreateParty(rosenTss, statusCh, errorCh, done)
+	if err != nil {
+		return err
+	}
+	s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+	rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 
 	for {
 		select {
 		case err := <-errorCh:
 			if err.Error() == "close channel" {
-				close(messageCh)
 				return nil
 			}
 			return err
 		case msg, ok := <-messageCh:
 			if !ok {
-				if s.LocalTssData.Party != nil {
-					s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-				}
+				s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
 				return fmt.Errorf("communication channel is closed")
 			}
 			s.Logger.Infof("received new message from {%s} on communication channel", msg.SenderId)
@@ -133,39 +165,38 @@ func (s *operationECDSAKeygen) StartAction(rosenTss _interface.RosenTss, message
 				return err
 			}
 			go func() {
-				for {
-					if s.LocalTssData.Party == nil {
-						time.Sleep(time.Duration(rosenTss.GetConfig().WaitInPartyMessageHandling) * time.Millisecond)
-					} else {
-						break
-					}
+				// messages are not routed into the party after the action is stopped
+				select {
+				case <-done:
+					return
+				default:
 				}
 				s.Logger.Debugf("party info: %+v", s.LocalTssData.Party)
-				err = s.PartyUpdate(partyMsg)
+				err := s.PartyUpdate(partyMsg)
 				if err != nil {
 					s.Logger.Errorf("there was an error in handling party message: %+v", err)
-					errorCh <- err
+					select {
+					case errorCh <- err:
+					case <-done:
+					}
 				}
 				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+				rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 				return
 			}()
 		case end := <-statusCh:
 			if end {
 				return nil
 			}
-		default:
-			if s.LocalTssData.Party == nil && !partyStarted {
-				partyStarted = true
-				s.CreateParty(rosenTss, statusCh, errorCh)
-				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-			}
 		}
 	}
 }
 
 //	- create ecdsa keygen operation
 func NewKeygenECDSAOperation(keygenMessage models.KeygenMessage) _interface.KeygenOperation {
-	logging = logger.NewSugar("ecdsa-keygen")
+	loggingOnce.Do(func() {
+		logging = logger.NewSugar("ecdsa-keygen")
+	})
 	return &operationECDSAKeygen{
 		StructKeygen: keygen.StructKeygen{
 			KeygenMessage: keygenMessage,
@@ -181,6 +212,7 @@ func (s *operationECDSAKeygen) GetClassName() string {
 }
 
 //	- handles party messages on out channel
+//	- records round of the message in progress of the operation
 //	- creates payload from party message
 //	- send it to NewMessage function
 func (s *operationECDSAKeygen) HandleOutMessage(rosenTss _interface.RosenTss, partyMsg tss.Message) error {
@@ -189,8 +221,9 @@ func (s *operationECDSAKeygen) HandleOutMessage(rosenTss _interface.RosenTss, pa
 		s.Logger.Errorf("there was an error in parsing party message to the struct: %+v", err)
 		return err
 	}
+	rosenTss.GetRegistry().Round(s.MessageId(), partyMsg)
 
-	messageId := s.GetClassName()
+	messageId := s.MessageId()
 	payload := models.Payload{
 		Message:   msgHex,
 		MessageId: messageId,
@@ -224,11 +257,12 @@ func (s *operationECDSAKeygen) HandleEndMessage(rosenTss _interface.RosenTss, ke
 	shareIDStr := keygenData.ShareID.String()
 
 	keygenResponse := models.KeygenData{
+		KeyId:   s.KeygenMessage.KeyId,
 		ShareID: shareIDStr,
 		PubKey:  encodedPK,
 		Status:  "success",
 	}
-	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA)
+	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA, s.KeygenMessage.KeyId)
 	if err != nil {
 		return err
 	}
@@ -240,12 +274,13 @@ func (s *operationECDSAKeygen) HandleEndMessage(rosenTss _interface.RosenTss, ke
 	s.Logger.Infof("hex pubKey: %v", encodedPK)
 	s.Logger.Infof("keygen process for ShareID: {%s} and Crypto: {%s} finished.", shareIDStr, s.KeygenMessage.Crypto)
 
-	err = rosenTss.GetStorage().WriteData(tssConfigECDSA, rosenTss.GetPeerHome(), keygen.KeygenFileName, models.ECDSA)
+	err = rosenTss.GetStorage().WriteData(tssConfigECDSA, rosenTss.GetPeerHome(), keygen.KeygenFileName, models.ECDSA, s.KeygenMessage.KeyId)
 	if err != nil {
 		return err
 	}
 
-	err = rosenTss.GetConnection().CallBack(s.KeygenMessage.CallBackUrl, keygenResponse)
+	rosenTss.GetRegistry().SetResult(s.MessageId(), keygenResponse)
+	err = rosenTss.CallBack(s.KeygenMessage.CallBackUrl, keygenResponse)
 	if err != nil {
 		return err
 	}
@@ -255,14 +290,19 @@ func (s *operationECDSAKeygen) HandleEndMessage(rosenTss _interface.RosenTss, ke
 
 //	- handles all party messages on outCh and endCh
 //	- listens to channels and send the message to the right function
+//	- stops when done is closed, channels of the stopped party are drained in the background
 func (s *operationECDSAKeygen) GossipMessageHandler(
-	rosenTss _interface.RosenTss, outCh chan tss.Message, endCh chan *ecdsaKeygen.LocalPartySaveData,
+	rosenTss _interface.RosenTss, outCh chan tss.Message, endCh chan *ecdsaKeygen.LocalPartySaveData, done chan struct{},
 ) (bool, error) {
 	for {
 		select {
+		case <-done:
+			utils.DrainParty(outCh, endCh)
+			return false, nil
 		case partyMsg := <-outCh:
 			err := s.HandleOutMessage(rosenTss, partyMsg)
 			if err != nil {
+				utils.DrainParty(outCh, endCh)
 				return false, err
 			}
 		case save := <-endCh:
@@ -279,6 +319,7 @@ func (s *operationECDSAKeygen) GossipMessageHandler(
 func (h *handler) StartParty(
 	localTssData *models.TssData,
 	threshold int,
+	preParams *ecdsaKeygen.LocalPreParams,
 	outCh chan tss.Message,
 	endCh chan *ecdsaKeygen.LocalPartySaveData,
 ) error {
@@ -293,7 +334,7 @@ func (h *handler) StartParty(
 			}
 		}
 		localTssData.Params = tss.NewParameters(tss.S256(), ctx, localPartyId, len(localTssData.PartyIds), threshold)
-		localTssData.Party = ecdsaKeygen.NewLocalParty(localTssData.Params, outCh, endCh)
+		localTssData.Party = ecdsaKeygen.NewLocalParty(localTssData.Params, outCh, endCh, *preParams)
 
 		if err := localTssData.Party.Start(); err != nil {
 			return err
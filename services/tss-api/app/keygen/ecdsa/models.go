@@ -11,6 +11,7 @@ type ECDSAHandler interface {
 	StartParty(
 		localTssData *models.TssData,
 		threshold int,
+		preParams *ecdsaKeygen.LocalPreParams,
 		outCh chan tss.Message,
 		endCh chan *ecdsaKeygen.LocalPartySaveData,
 	) error
@@ -19,6 +20,7 @@ type ECDSAHandler interface {
 type operationECDSAKeygen struct {
 	keygen.StructKeygen
 	ECDSAHandler
+	preParams *ecdsaKeygen.LocalPreParams
 }
 
 type handler struct{}
@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 	_interface "rosen-bridge/tss-api/app/interface"
 	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
 )
 
 const (
@@ -18,6 +19,16 @@ type StructKeygen struct {
 	Logger        *zap.SugaredLogger
 }
 
+//	- returns the keyId of the keygen
+func (s *StructKeygen) GetKeyId() string {
+	return s.KeygenMessage.KeyId
+}
+
+//	- returns the messageId of keygen messages
+func (s *StructKeygen) MessageId() string {
+	return utils.KeyMessageId(fmt.Sprintf("%s%s", s.KeygenMessage.Crypto, "Keygen"), s.KeygenMessage.KeyId)
+}
+
 //	- creates a gossip message from payload.
 //	- sends the gossip message to Publish function.
 func (s *StructKeygen) NewMessage(rosenTss _interface.RosenTss, payload models.Payload, receiver string) error {
@@ -29,15 +40,23 @@ func (s *StructKeygen) NewMessage(rosenTss _interface.RosenTss, payload models.P
 		SenderId:   payload.SenderId,
 		ReceiverId: receiver,
 	}
-	err := rosenTss.GetConnection().Publish(gossipMessage)
+	err := rosenTss.Publish(gossipMessage)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+//	- checks the sender is a party of the operation
 //	- Updates party on received message destination.
 func (s *StructKeygen) PartyUpdate(partyMsg models.PartyMessage) error {
+	// the sender of the wire is replaced with the party of the operation
+	from, err := utils.PartySender(partyMsg.GetFrom, s.LocalTssData.PartyIds)
+	if err != nil {
+		return err
+	}
+	partyMsg.GetFrom = from
+
 	dest := partyMsg.GetTo
 	if dest == nil { // broadcast!
 		if s.LocalTssData.Party.PartyID().Index == partyMsg.GetFrom.Index {
@@ -52,7 +71,7 @@ func (s *StructKeygen) PartyUpdate(partyMsg models.PartyMessage) error {
 		s.Logger.Infof("updating party state with p2p message")
 	}
 
-	err := s.KeygenOperationHandler.SharedPartyUpdater(s.LocalTssData.Party, partyMsg)
+	err = s.KeygenOperationHandler.SharedPartyUpdater(s.LocalTssData.Party, partyMsg)
 	if err != nil {
 		return err
 	}
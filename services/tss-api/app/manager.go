@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+
+	"rosen-bridge/tss-api/app/interface"
+	"rosen-bridge/tss-api/metrics"
+	"rosen-bridge/tss-api/models"
+)
+
+//	message channel of an operation, it is owned by the manager and never closed,
+//	done is closed when the channel is removed so the senders stop waiting on it
+type operationChannel struct {
+	messageCh chan models.GossipMessage
+	done      chan struct{}
+}
+
+//	keeps message channels and running operations of the app, all accesses are guarded by the lock
+type operationManager struct {
+	lock     sync.RWMutex
+	channels map[string]*operationChannel
+	keygens  map[string]_interface.KeygenOperation
+	signs    map[string]_interface.SignOperation
+	regroups map[string]_interface.RegroupOperation
+	batches  map[string]*signBatch
+}
+
+//	Constructor of an operation manager
+func newOperationManager() *operationManager {
+	return &operationManager{
+		channels: make(map[string]*operationChannel),
+		keygens:  make(map[string]_interface.KeygenOperation),
+		signs:    make(map[string]_interface.SignOperation),
+		regroups: make(map[string]_interface.RegroupOperation),
+		batches:  make(map[string]*signBatch),
+	}
+}
+
+//	creates the message channel of messageId, returns error if it exists
+func (m *operationManager) addChannel(messageId string) (*operationChannel, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.channels[messageId]; ok {
+		return nil, fmt.Errorf(models.DuplicatedMessageIdError)
+	}
+	channel := &operationChannel{
+		messageCh: make(chan models.GossipMessage, 100),
+		done:      make(chan struct{}),
+	}
+	m.channels[messageId] = channel
+	metrics.SetChannels(len(m.channels))
+	return channel, nil
+}
+
+//	returns the message channel of messageId
+func (m *operationManager) getChannel(messageId string) (*operationChannel, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	channel, ok := m.channels[messageId]
+	return channel, ok
+}
+
+//	removes the message channel of messageId and releases its senders
+func (m *operationManager) removeChannel(messageId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if channel, ok := m.channels[messageId]; ok {
+		close(channel.done)
+		delete(m.channels, messageId)
+		metrics.SetChannels(len(m.channels))
+	}
+}
+
+//	adds a running keygen operation
+func (m *operationManager) addKeygen(channelId string, operation _interface.KeygenOperation) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.keygens[channelId] = operation
+}
+
+//	removes a keygen operation and returns it
+func (m *operationManager) removeKeygen(channelId string) _interface.KeygenOperation {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	operation := m.keygens[channelId]
+	delete(m.keygens, channelId)
+	return operation
+}
+
+//	returns a copy of running keygen operations
+func (m *operationManager) keygenOperations() map[string]_interface.KeygenOperation {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	operations := make(map[string]_interface.KeygenOperation, len(m.keygens))
+	for channelId, operation := range m.keygens {
+		operations[channelId] = operation
+	}
+	return operations
+}
+
+//	adds a running sign operation
+func (m *operationManager) addSign(channelId string, operation _interface.SignOperation) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.signs[channelId] = operation
+}
+
+//	removes a sign operation and returns it
+func (m *operationManager) removeSign(channelId string) _interface.SignOperation {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	operation := m.signs[channelId]
+	delete(m.signs, channelId)
+	return operation
+}
+
+//	returns a copy of running sign operations
+func (m *operationManager) signOperations() map[string]_interface.SignOperation {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	operations := make(map[string]_interface.SignOperation, len(m.signs))
+	for channelId, operation := range m.signs {
+		operations[channelId] = operation
+	}
+	return operations
+}
+
+//	adds a running regroup operation
+func (m *operationManager) addRegroup(channelId string, operation _interface.RegroupOperation) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.regroups[channelId] = operation
+}
+
+//	removes a regroup operation and returns it
+func (m *operationManager) removeRegroup(channelId string) _interface.RegroupOperation {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	operation := m.regroups[channelId]
+	delete(m.regroups, channelId)
+	return operation
+}
+
+//	returns a copy of running regroup operations
+func (m *operationManager) regroupOperations() map[string]_interface.RegroupOperation {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	operations := make(map[string]_interface.RegroupOperation, len(m.regroups))
+	for channelId, operation := range m.regroups {
+		operations[channelId] = operation
+	}
+	return operations
+}
+
+//	adds a running sign batch, returns error if a batch of the same messages is running
+func (m *operationManager) addBatch(batch *signBatch) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.batches[batch.messageId]; ok {
+		return fmt.Errorf(models.DuplicatedMessageIdError)
+	}
+	m.batches[batch.messageId] = batch
+	return nil
+}
+
+//	returns the running sign batch of messageId
+func (m *operationManager) getBatch(messageId string) (*signBatch, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	batch, ok := m.batches[messageId]
+	return batch, ok
+}
+
+//	removes the sign batch of messageId
+func (m *operationManager) removeBatch(messageId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.batches, messageId)
+}
@@ -0,0 +1,306 @@
+package app
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"rosen-bridge/tss-api/app/interface"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/operations"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tss-app-test")
+	if err != nil {
+		panic(err)
+	}
+	err = logger.Init(filepath.Join(dir, "tss.log"), models.Config{LogLevel: logger.ErrorLevelStr}, false)
+	if err != nil {
+		panic(err)
+	}
+	logging = logger.NewSugar("app")
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//	sign operation which forwards the received messages and stops on the first error
+type testSignOperation struct {
+	keyId    string
+	received chan models.GossipMessage
+}
+
+func (o *testSignOperation) Init(_interface.RosenTss, []models.Peer) error {
+	return nil
+}
+
+func (o *testSignOperation) StartAction(_ _interface.RosenTss, messageCh chan models.GossipMessage, errorCh chan error) error {
+	for {
+		select {
+		case err := <-errorCh:
+			return err
+		case msg := <-messageCh:
+			o.received <- msg
+		}
+	}
+}
+
+func (o *testSignOperation) GetClassName() string {
+	return "testSign"
+}
+
+func (o *testSignOperation) GetKeyId() string {
+	return o.keyId
+}
+
+func newTestRosenTss() *rosenTss {
+	config := models.Config{MessageTimeout: 5, WriteMsgRetryTime: 10}
+	return &rosenTss{
+		manager:  newOperationManager(),
+		metaData: make(map[string]models.MetaData),
+		registry: operations.NewRegistry(config),
+		Config:   config,
+	}
+}
+
+//	returns a p2p message of the sender for the operation of messageId
+func testMessage(t *testing.T, messageId string, sender string) models.Message {
+	partyMessage := models.PartyMessage{
+		Message:     []byte("message"),
+		GetFrom:     tss.NewPartyID(sender, sender, big.NewInt(1)),
+		IsBroadcast: true,
+	}
+	partyMessageBytes, err := json.Marshal(partyMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gossipMessage := models.GossipMessage{
+		Message:   hex.EncodeToString(partyMessageBytes),
+		MessageId: messageId,
+		SenderId:  sender,
+	}
+	gossipMessageBytes, err := json.Marshal(gossipMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.Message{Message: string(gossipMessageBytes), Sender: sender}
+}
+
+//	waits until the operation is in the state
+func waitForState(t *testing.T, r *rosenTss, operationId string, state string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		operation, err := r.registry.Get(operationId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if operation.State == state {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation is %s, expected %s", operation.State, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOperationManagerConcurrentChannels(t *testing.T) {
+	manager := newOperationManager()
+	const workers = 16
+	const messageIds = 8
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	added := make(map[string]int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < messageIds; j++ {
+				messageId := fmt.Sprintf("message%d", j)
+				if _, err := manager.addChannel(messageId); err == nil {
+					lock.Lock()
+					added[messageId]++
+					lock.Unlock()
+				} else if err.Error() != models.DuplicatedMessageIdError {
+					t.Errorf("unexpected error: %v", err)
+				}
+				manager.getChannel(messageId)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for j := 0; j < messageIds; j++ {
+		messageId := fmt.Sprintf("message%d", j)
+		if added[messageId] != 1 {
+			t.Errorf("channel %s is added %d times", messageId, added[messageId])
+		}
+		channel, ok := manager.getChannel(messageId)
+		if !ok {
+			t.Fatalf("channel %s is not found", messageId)
+		}
+		manager.removeChannel(messageId)
+		// removing is idempotent and releases the senders of the channel
+		manager.removeChannel(messageId)
+		select {
+		case <-channel.done:
+		default:
+			t.Errorf("done of channel %s is not closed", messageId)
+		}
+		if _, ok := manager.getChannel(messageId); ok {
+			t.Errorf("channel %s is not removed", messageId)
+		}
+	}
+}
+
+func TestOperationManagerConcurrentOperations(t *testing.T) {
+	manager := newOperationManager()
+	const workers = 16
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			channelId := fmt.Sprintf("channel%d", i)
+			operation := &testSignOperation{keyId: channelId}
+			manager.addSign(channelId, operation)
+			for channelId, operation := range manager.signOperations() {
+				if operation.GetKeyId() != channelId {
+					t.Errorf("operation of %s is %s", channelId, operation.GetKeyId())
+				}
+			}
+			if i%2 == 0 {
+				if removed := manager.removeSign(channelId); removed != operation {
+					t.Errorf("removed operation of %s is not the added one", channelId)
+				}
+			}
+			manager.keygenOperations()
+			manager.regroupOperations()
+		}(i)
+	}
+	wg.Wait()
+
+	if count := len(manager.signOperations()); count != workers/2 {
+		t.Errorf("%d sign operations are running, expected %d", count, workers/2)
+	}
+}
+
+func TestMessageHandlerConcurrentRoute(t *testing.T) {
+	r := newTestRosenTss()
+	const senders = 4
+	const messages = 20
+	messageId := "routeMessage"
+
+	// messages which arrive before the operation wait for its channel
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sender := fmt.Sprintf("peer%d", i)
+			for j := 0; j < messages; j++ {
+				if err := r.MessageHandler(testMessage(t, messageId, sender)); err != nil {
+					t.Errorf("unable to handle message: %v", err)
+				}
+			}
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	channel, err := r.manager.addChannel(messageId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	received := make(map[string]int)
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < senders*messages; i++ {
+		select {
+		case msg := <-channel.messageCh:
+			received[msg.SenderId]++
+		case <-timeout:
+			t.Fatalf("%d of %d messages are routed", i, senders*messages)
+		}
+	}
+	for i := 0; i < senders; i++ {
+		sender := fmt.Sprintf("peer%d", i)
+		if received[sender] != messages {
+			t.Errorf("%d messages of %s are routed, expected %d", received[sender], sender, messages)
+		}
+	}
+	r.manager.removeChannel(messageId)
+}
+
+func TestOperationLifecycle(t *testing.T) {
+	r := newTestRosenTss()
+	messageId := "lifecycleMessage"
+	channelId := "testSign" + messageId
+
+	channel, err := r.manager.addChannel(messageId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operation := &testSignOperation{keyId: "default", received: make(chan models.GossipMessage, 10)}
+	r.manager.addSign(channelId, operation)
+	operationId := r.registry.Add("sign", models.EDDSA, "default", messageId)
+
+	errorCh := make(chan error)
+	r.timeOutGoRoutine(operation.GetClassName(), 60, messageId, operationId, errorCh)
+	r.registry.SetState(operationId, models.OperationWaitingForPeers)
+	result := make(chan error)
+	go func() {
+		err := operation.StartAction(r, channel.messageCh, errorCh)
+		r.registry.Finish(operationId, err)
+		r.deleteInstance("sign", messageId, channelId)
+		result <- err
+	}()
+
+	if err := r.MessageHandler(testMessage(t, messageId, "peer1")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-operation.received:
+		if msg.SenderId != "peer1" {
+			t.Errorf("message of %s is received, expected peer1", msg.SenderId)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not routed to the operation")
+	}
+	// the operation is marked as running after the message is passed to its channel
+	waitForState(t, r, operationId, models.OperationRunning)
+
+	if err := r.registry.Cancel(operationId); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-result:
+		if err == nil || err.Error() != models.OperationCancelledError {
+			t.Errorf("operation is stopped with %v, expected cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation is not stopped by cancellation")
+	}
+
+	waitForState(t, r, operationId, models.OperationCancelled)
+	if _, ok := r.manager.getChannel(messageId); ok {
+		t.Error("channel of the finished operation is not removed")
+	}
+	if len(r.GetSignOperations()) != 0 {
+		t.Error("finished operation is not removed")
+	}
+	// messages of a finished operation are dropped instead of blocking
+	if err := r.MessageHandler(testMessage(t, messageId, "peer2")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,30 @@
+package ecdsa
+
+import (
+	ecdsaKeygen "github.com/bnb-chain/tss-lib/v2/ecdsa/keygen"
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"rosen-bridge/tss-api/app/regroup"
+	"rosen-bridge/tss-api/models"
+)
+
+type ECDSAHandler interface {
+	StartParty(
+		localTssData *models.TssData,
+		oldPartyIds tss.SortedPartyIDs,
+		newPartyIds tss.SortedPartyIDs,
+		oldThreshold int,
+		newThreshold int,
+		key ecdsaKeygen.LocalPartySaveData,
+		outCh chan tss.Message,
+		endCh chan *ecdsaKeygen.LocalPartySaveData,
+	) error
+}
+
+type operationECDSARegroup struct {
+	regroup.StructRegroup
+	ECDSAHandler
+	savedData ecdsaKeygen.LocalPartySaveData
+	preParams *ecdsaKeygen.LocalPreParams
+}
+
+type handler struct{}
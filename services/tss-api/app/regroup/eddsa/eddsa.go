@@ -0,0 +1,337 @@
+package eddsa
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	eddsaKeygen "github.com/bnb-chain/tss-lib/v2/eddsa/keygen"
+	eddsaResharing "github.com/bnb-chain/tss-lib/v2/eddsa/resharing"
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/app/interface"
+	"rosen-bridge/tss-api/app/keygen"
+	"rosen-bridge/tss-api/app/regroup"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+	"sync"
+)
+
+var logging *zap.SugaredLogger
+var loggingOnce sync.Once
+
+var eddsaHandler handler
+
+//	- Initializes the eddsa regroup partyIds of old and new committees
+//	- loads keygen data if peer is in the old committee
+func (s *operationEDDSARegroup) Init(rosenTss _interface.RosenTss, oldPeers []models.Peer, newP2PIDs []string) error {
+
+	s.Logger.Info("initiation eddsa regroup process")
+
+	err := s.InitPeers(rosenTss, oldPeers, newP2PIDs)
+	if err != nil {
+		return err
+	}
+
+	if s.OldTssData.PartyID != nil {
+		data, _, err := rosenTss.GetStorage().LoadEDDSAKeygen(rosenTss.GetPeerHome(), rosenTss.GetP2pId(), s.RegroupMessage.KeyId)
+		if err != nil {
+			s.Logger.Error(err)
+			return err
+		}
+		if s.OldTssData.PartyID.KeyInt().Cmp(data.KeygenData.ShareID) != 0 {
+			return fmt.Errorf(models.WrongRegroupPeersError)
+		}
+		for _, peer := range s.OldTssData.PartyIds {
+			if utils.IndexOf(data.KeygenData.Ks, peer.KeyInt()) == -1 {
+				return fmt.Errorf(models.WrongRegroupPeersError)
+			}
+		}
+		s.savedData = data.KeygenData
+	}
+
+	return nil
+}
+
+//	- creates end and out channel for parties,
+//	- calls StartParty function of protocol for new and old committee
+//	- handles end channels and out channel in a go routine
+func (s *operationEDDSARegroup) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error, done chan struct{}) error {
+	s.Logger.Info("creating and starting parties")
+
+	outCh := make(chan tss.Message, len(s.OldTssData.PartyIds)+len(s.NewTssData.PartyIds))
+	var oldEndCh, newEndCh chan *eddsaKeygen.LocalPartySaveData
+
+	// the new party is started first, so it is ready for messages of the local old party
+	if s.NewTssData.PartyID != nil {
+		newEndCh = make(chan *eddsaKeygen.LocalPartySaveData, len(s.NewTssData.PartyIds))
+		key := eddsaKeygen.NewLocalPartySaveData(len(s.NewTssData.PartyIds))
+		err := s.StartParty(
+			&s.NewTssData, s.OldTssData.PartyIds, s.NewTssData.PartyIds,
+			s.RegroupMessage.OldThreshold, s.RegroupMessage.NewThreshold, key, outCh, newEndCh,
+		)
+		if err != nil {
+			s.Logger.Errorf("there was an error in starting new committee party: %+v", err)
+			return err
+		}
+	}
+	if s.OldTssData.PartyID != nil {
+		oldEndCh = make(chan *eddsaKeygen.LocalPartySaveData, len(s.OldTssData.PartyIds))
+		err := s.StartParty(
+			&s.OldTssData, s.OldTssData.PartyIds, s.NewTssData.PartyIds,
+			s.RegroupMessage.OldThreshold, s.RegroupMessage.NewThreshold, s.savedData, outCh, oldEndCh,
+		)
+		if err != nil {
+			s.Logger.Errorf("there was an error in starting old committee party: %+v", err)
+			return err
+		}
+	}
+
+	go func() {
+		result, err := s.GossipMessageHandler(rosenTss, outCh, oldEndCh, newEndCh, done)
+		select {
+		case <-done:
+			// the action is already stopped (cancelled or timed out)
+			return
+		default:
+		}
+		if err != nil {
+			s.Logger.Error(err)
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
+			return
+		}
+		if !result {
+			err = fmt.Errorf("close channel")
+			s.Logger.Error(err)
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
+			return
+		} else {
+			s.Logger.Infof("end parties successfully")
+			select {
+			case statusCh <- true:
+			case <-done:
+			}
+			return
+		}
+	}()
+	return nil
+}
+
+//	- reads new gossip messages from channel and handle it by calling related function in a go routine.
+func (s *operationEDDSARegroup) StartAction(rosenTss _interface.RosenTss, messageCh chan models.GossipMessage, errorCh chan error) error {
+
+	statusCh := make(chan bool)
+	// done is closed when the action returns, so goroutines of the party stop instead of blocking
+	done := make(chan struct{})
+	defer close(done)
+
+	// the parties are created before any message is handled, so goroutines of the messages only read them
+	err := s.CreateParty(rosenTss, statusCh, errorCh, done)
+	if err != nil {
+		return err
+	}
+	rosenTss.GetRegistry().Progress(s.MessageId(), s.OldTssData.Party, s.NewTssData.Party)
+
+	for {
+		select {
+		case err := <-errorCh:
+			if err.Error() == "close channel" {
+				return nil
+			}
+			return err
+		case msg, ok := <-messageCh:
+			if !ok {
+				if s.OldTssData.Party != nil {
+					s.Logger.Infof("old party was waiting for: %+v", s.OldTssData.Party.WaitingFor())
+				}
+				if s.NewTssData.Party != nil {
+					s.Logger.Infof("new party was waiting for: %+v", s.NewTssData.Party.WaitingFor())
+				}
+				return fmt.Errorf("communication channel is closed")
+			}
+			s.Logger.Infof("received new message from {%s} on communication channel", msg.SenderId)
+			msgBytes, err := utils.HexDecoder(msg.Message)
+			if err != nil {
+				return err
+			}
+			partyMsg := models.PartyMessage{}
+			err = json.Unmarshal(msgBytes, &partyMsg)
+			if err != nil {
+				return err
+			}
+			go func() {
+				// messages are not routed into the party after the action is stopped
+				select {
+				case <-done:
+					return
+				default:
+				}
+				err := s.PartyUpdate(partyMsg)
+				if err != nil {
+					s.Logger.Errorf("there was an error in handling party message: %+v", err)
+					select {
+					case errorCh <- err:
+					case <-done:
+					}
+				}
+				rosenTss.GetRegistry().Progress(s.MessageId(), s.OldTssData.Party, s.NewTssData.Party)
+				return
+			}()
+		case end := <-statusCh:
+			if end {
+				return nil
+			}
+		}
+	}
+}
+
+//	- create eddsa regroup operation
+func NewRegroupEDDSAOperation(regroupMessage models.RegroupMessage) _interface.RegroupOperation {
+	loggingOnce.Do(func() {
+		logging = logger.NewSugar("eddsa-regroup")
+	})
+	return &operationEDDSARegroup{
+		StructRegroup: regroup.StructRegroup{
+			RegroupMessage: regroupMessage,
+			Logger:         logging,
+		},
+		EDDSAHandler: &eddsaHandler,
+	}
+}
+
+//	- returns the class name
+func (s *operationEDDSARegroup) GetClassName() string {
+	return "eddsaRegroup"
+}
+
+//	- handles save data (new keygen data) of the new committee party
+//	- writes the new keygen data and meta data if peer is in the new committee, archives its key share otherwise
+//	- sends the result to CallBack
+func (s *operationEDDSARegroup) HandleEndMessage(rosenTss _interface.RosenTss, keygenData *eddsaKeygen.LocalPartySaveData) error {
+
+	regroupResponse := models.RegroupData{
+		KeyId:  s.RegroupMessage.KeyId,
+		Status: "success",
+	}
+
+	if keygenData != nil {
+		pkX, pkY := keygenData.EDDSAPub.X(), keygenData.EDDSAPub.Y()
+		regroupResponse.PubKey = hex.EncodeToString(utils.GetPKFromEDDSAPub(pkX, pkY))
+		regroupResponse.ShareID = keygenData.ShareID.String()
+
+		meta := models.MetaData{
+			PeersCount: len(s.NewTssData.PartyIds),
+			Threshold:  s.RegroupMessage.NewThreshold,
+		}
+		tssConfigEDDSA := models.TssConfigEDDSA{
+			MetaData:   meta,
+			KeygenData: *keygenData,
+		}
+		err := rosenTss.GetStorage().WriteData(tssConfigEDDSA, rosenTss.GetPeerHome(), keygen.KeygenFileName, models.EDDSA, s.RegroupMessage.KeyId)
+		if err != nil {
+			return err
+		}
+		err = rosenTss.SetMetaData(meta, models.EDDSA, s.RegroupMessage.KeyId)
+		if err != nil {
+			return err
+		}
+	} else {
+		pkX, pkY := s.savedData.EDDSAPub.X(), s.savedData.EDDSAPub.Y()
+		regroupResponse.PubKey = hex.EncodeToString(utils.GetPKFromEDDSAPub(pkX, pkY))
+		// the share of a peer which left the committee is archived, so it can not be loaded for signing anymore
+		archivePath, err := rosenTss.GetStorage().ArchiveKey(rosenTss.GetPeerHome(), models.EDDSA, s.RegroupMessage.KeyId)
+		if err != nil {
+			return err
+		}
+		err = rosenTss.SetMetaData(models.MetaData{}, models.EDDSA, s.RegroupMessage.KeyId)
+		if err != nil {
+			return err
+		}
+		s.Logger.Warnf("peer is not in the new committee, its eddsa key share is archived in %s", archivePath)
+	}
+
+	s.Logger.Infof("hex pubKey: %v", regroupResponse.PubKey)
+	s.Logger.Infof("regroup process for ShareID: {%s} and Crypto: {%s} finished.", regroupResponse.ShareID, s.RegroupMessage.Crypto)
+
+	rosenTss.GetRegistry().SetResult(s.MessageId(), regroupResponse)
+	err := rosenTss.CallBack(s.RegroupMessage.CallBackUrl, regroupResponse)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//	- handles all party messages on outCh and end channels of old and new parties
+//	- listens to channels and send the message to the right function
+//	- stops when done is closed, channels of the stopped parties are drained in the background
+func (s *operationEDDSARegroup) GossipMessageHandler(
+	rosenTss _interface.RosenTss,
+	outCh chan tss.Message,
+	oldEndCh chan *eddsaKeygen.LocalPartySaveData,
+	newEndCh chan *eddsaKeygen.LocalPartySaveData,
+	done chan struct{},
+) (bool, error) {
+	var newSave *eddsaKeygen.LocalPartySaveData
+	for oldEndCh != nil || newEndCh != nil {
+		select {
+		case <-done:
+			utils.DrainParty(outCh, oldEndCh, newEndCh)
+			return false, nil
+		case partyMsg := <-outCh:
+			err := s.HandleOutMessage(rosenTss, partyMsg)
+			if err != nil {
+				utils.DrainParty(outCh, oldEndCh, newEndCh)
+				return false, err
+			}
+		case <-oldEndCh:
+			s.Logger.Infof("old committee party finished")
+			oldEndCh = nil
+		case save := <-newEndCh:
+			s.Logger.Infof("new committee party finished")
+			newSave = save
+			newEndCh = nil
+		}
+	}
+	err := s.HandleEndMessage(rosenTss, newSave)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//	- creates tss resharing parameters and party
+func (h *handler) StartParty(
+	localTssData *models.TssData,
+	oldPartyIds tss.SortedPartyIDs,
+	newPartyIds tss.SortedPartyIDs,
+	oldThreshold int,
+	newThreshold int,
+	key eddsaKeygen.LocalPartySaveData,
+	outCh chan tss.Message,
+	endCh chan *eddsaKeygen.LocalPartySaveData,
+) error {
+	if localTssData.Party == nil {
+		logging.Info("creating party parameters")
+		oldCtx := tss.NewPeerContext(oldPartyIds)
+		newCtx := tss.NewPeerContext(newPartyIds)
+		params := tss.NewReSharingParameters(
+			tss.Edwards(), oldCtx, newCtx, localTssData.PartyID,
+			len(oldPartyIds), oldThreshold, len(newPartyIds), newThreshold,
+		)
+		localTssData.Params = params.Parameters
+		localTssData.Party = eddsaResharing.NewLocalParty(params, key, outCh, endCh)
+
+		if err := localTssData.Party.Start(); err != nil {
+			return err
+		}
+		logging.Info("party started")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package eddsa
+
+import (
+	eddsaKeygen "github.com/bnb-chain/tss-lib/v2/eddsa/keygen"
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"rosen-bridge/tss-api/app/regroup"
+	"rosen-bridge/tss-api/models"
+)
+
+type EDDSAHandler interface {
+	StartParty(
+		localTssData *models.TssData,
+		oldPartyIds tss.SortedPartyIDs,
+		newPartyIds tss.SortedPartyIDs,
+		oldThreshold int,
+		newThreshold int,
+		key eddsaKeygen.LocalPartySaveData,
+		outCh chan tss.Message,
+		endCh chan *eddsaKeygen.LocalPartySaveData,
+	) error
+}
+
+type operationEDDSARegroup struct {
+	regroup.StructRegroup
+	EDDSAHandler
+	savedData eddsaKeygen.LocalPartySaveData
+}
+
+type handler struct{}
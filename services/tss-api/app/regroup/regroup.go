@@ -0,0 +1,204 @@
+package regroup
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/blake2b"
+	"math/big"
+	_interface "rosen-bridge/tss-api/app/interface"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+	"sort"
+)
+
+type StructRegroup struct {
+	_interface.RegroupOperationHandler
+	OldTssData     models.TssData
+	NewTssData     models.TssData
+	RegroupMessage models.RegroupMessage
+	Logger         *zap.SugaredLogger
+}
+
+//	- creates old and new committee party ids
+//	- sets the local party id in each committee that this peer belongs to
+func (s *StructRegroup) InitPeers(rosenTss _interface.RosenTss, oldPeers []models.Peer, newP2PIDs []string) error {
+	selfP2PID := rosenTss.GetP2pId()
+
+	var unsortedOldPeers []*tss.PartyID
+	for _, peer := range oldPeers {
+		moniker := fmt.Sprintf("tssPeer/%s", peer.P2PID)
+		shareID, ok := new(big.Int).SetString(peer.ShareID, 10)
+		if !ok {
+			return fmt.Errorf("invalid shareID for peer %s", peer.P2PID)
+		}
+		newPartyID := tss.NewPartyID(peer.P2PID, moniker, shareID)
+		unsortedOldPeers = append(unsortedOldPeers, newPartyID)
+		if peer.P2PID == selfP2PID {
+			s.OldTssData.PartyID = newPartyID
+		}
+	}
+
+	var unsortedNewPeers []*tss.PartyID
+	for _, peer := range newP2PIDs {
+		moniker := fmt.Sprintf("tssPeer/%s", peer)
+		newPartyID := tss.NewPartyID(peer, moniker, NewShareID(peer, oldPeers))
+		unsortedNewPeers = append(unsortedNewPeers, newPartyID)
+		if peer == selfP2PID {
+			s.NewTssData.PartyID = newPartyID
+		}
+	}
+
+	if s.OldTssData.PartyID == nil && s.NewTssData.PartyID == nil {
+		return fmt.Errorf(models.NotInRegroupCommitteeError)
+	}
+
+	s.OldTssData.PartyIds = tss.SortPartyIDs(unsortedOldPeers)
+	s.NewTssData.PartyIds = tss.SortPartyIDs(unsortedNewPeers)
+
+	s.Logger.Infof("old committee local PartyId: %+v", s.OldTssData.PartyID)
+	s.Logger.Infof("new committee local PartyId: %+v", s.NewTssData.PartyID)
+
+	return nil
+}
+
+//	- returns the share id of a peer in the new committee
+//	- it is derived from the p2pId and the old committee share ids, so it never collides with the old share of the same peer
+func NewShareID(p2pId string, oldPeers []models.Peer) *big.Int {
+	var oldShareIDs []string
+	for _, peer := range oldPeers {
+		oldShareIDs = append(oldShareIDs, peer.ShareID)
+	}
+	sort.Strings(oldShareIDs)
+
+	data := utils.Base58Decoder(p2pId)
+	for _, shareID := range oldShareIDs {
+		data = append(data, []byte(shareID)...)
+	}
+	hash := blake2b.Sum256(data)
+	return new(big.Int).SetBytes(hash[:])
+}
+
+//	- returns the messageId of regroup messages
+func (s *StructRegroup) MessageId() string {
+	return utils.KeyMessageId(fmt.Sprintf("%s%s", s.RegroupMessage.Crypto, "Regroup"), s.RegroupMessage.KeyId)
+}
+
+//	- returns the keyId of the regroup
+func (s *StructRegroup) GetKeyId() string {
+	return s.RegroupMessage.KeyId
+}
+
+//	- creates a gossip message from payload.
+//	- sends the gossip message to Publish function.
+func (s *StructRegroup) NewMessage(rosenTss _interface.RosenTss, payload models.Payload, receiver string) error {
+	s.Logger.Infof("creating new gossip message")
+
+	gossipMessage := models.GossipMessage{
+		Message:    payload.Message,
+		MessageId:  payload.MessageId,
+		SenderId:   payload.SenderId,
+		ReceiverId: receiver,
+	}
+	err := rosenTss.Publish(gossipMessage)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+//	- handles party messages on out channel
+//	- records round of the message in progress of the operation
+//	- creates payload from party message
+//	- delivers messages to the local parties directly and sends the rest to NewMessage function
+func (s *StructRegroup) HandleOutMessage(rosenTss _interface.RosenTss, partyMsg tss.Message) error {
+	msgHex, err := s.RegroupOperationHandler.PartyMessageHandler(partyMsg)
+	if err != nil {
+		s.Logger.Errorf("there was an error in parsing party message to the struct: %+v", err)
+		return err
+	}
+	rosenTss.GetRegistry().Round(s.MessageId(), partyMsg)
+
+	payload := models.Payload{
+		Message:   msgHex,
+		MessageId: s.MessageId(),
+		SenderId:  rosenTss.GetP2pId(),
+	}
+
+	if partyMsg.GetTo() == nil {
+		return s.NewMessage(rosenTss, payload, "")
+	}
+
+	// a peer may be in both committees, so each receiver gets the message once
+	sent := make(map[string]bool)
+	for _, peer := range partyMsg.GetTo() {
+		if sent[peer.Id] {
+			continue
+		}
+		sent[peer.Id] = true
+		if peer.Id == rosenTss.GetP2pId() {
+			err = s.localUpdate(msgHex)
+		} else {
+			err = s.NewMessage(rosenTss, payload, peer.Id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//	- passes a message from one local party to the other one
+func (s *StructRegroup) localUpdate(msgHex string) error {
+	msgBytes, err := utils.HexDecoder(msgHex)
+	if err != nil {
+		return err
+	}
+	partyMsg := models.PartyMessage{}
+	err = json.Unmarshal(msgBytes, &partyMsg)
+	if err != nil {
+		return err
+	}
+	// party update may write on out channel, so it should not block the out message handler
+	go func() {
+		err := s.PartyUpdate(partyMsg)
+		if err != nil {
+			s.Logger.Errorf("there was an error in handling local party message: %+v", err)
+		}
+	}()
+	return nil
+}
+
+//	- checks the sender is a party of the old or new committee
+//	- Updates local parties on received message destination.
+func (s *StructRegroup) PartyUpdate(partyMsg models.PartyMessage) error {
+	// the sender of the wire is replaced with the party of the committees
+	from, err := utils.PartySender(partyMsg.GetFrom, s.OldTssData.PartyIds, s.NewTssData.PartyIds)
+	if err != nil {
+		return err
+	}
+	partyMsg.GetFrom = from
+
+	for _, party := range []tss.Party{s.OldTssData.Party, s.NewTssData.Party} {
+		if party == nil || !isReceiver(party, partyMsg) {
+			continue
+		}
+		s.Logger.Infof("updating party %s state with regroup message", party.PartyID())
+		err = s.RegroupOperationHandler.SharedPartyUpdater(party, partyMsg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//	- returns true if the party is one of the message destinations
+func isReceiver(party tss.Party, partyMsg models.PartyMessage) bool {
+	for _, dest := range partyMsg.GetTo {
+		if dest.KeyInt().Cmp(party.PartyID().KeyInt()) == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -2,99 +2,140 @@ package app
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	ecdsaKeygen "rosen-bridge/tss-api/app/keygen/ecdsa"
 	eddsaKeygen "rosen-bridge/tss-api/app/keygen/eddsa"
+	ecdsaRegroup "rosen-bridge/tss-api/app/regroup/ecdsa"
+	eddsaRegroup "rosen-bridge/tss-api/app/regroup/eddsa"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/bnb-chain/tss-lib/v2/tss"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/blake2b"
 	"rosen-bridge/tss-api/app/interface"
 	ecdsaSign "rosen-bridge/tss-api/app/sign/ecdsa"
 	eddsaSign "rosen-bridge/tss-api/app/sign/eddsa"
+	"rosen-bridge/tss-api/identity"
+	"rosen-bridge/tss-api/integrity"
 	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
 	"rosen-bridge/tss-api/models"
 	"rosen-bridge/tss-api/network"
+	"rosen-bridge/tss-api/operations"
+	"rosen-bridge/tss-api/outbox"
+	"rosen-bridge/tss-api/preparams"
+	"rosen-bridge/tss-api/queue"
+	"rosen-bridge/tss-api/signatures"
 	"rosen-bridge/tss-api/storage"
 	"rosen-bridge/tss-api/utils"
 )
 
 type rosenTss struct {
-	ChannelMap         map[string]chan models.GossipMessage
-	KeygenOperationMap map[string]_interface.KeygenOperation
-	SignOperationMap   map[string]_interface.SignOperation
-	eddsaMetaData      models.MetaData
-	ecdsaMetaData      models.MetaData
-	storage            storage.Storage
-	connection         network.Connection
-	Config             models.Config
-	trustKey           string
-	peerHome           string
-	P2pId              string
+	manager    *operationManager
+	metaLock   sync.RWMutex
+	metaData   map[string]models.MetaData
+	storage    storage.Storage
+	connection network.Connection
+	preParams  preparams.PreParams
+	identity   identity.Identity
+	outbox     outbox.Outbox
+	registry   operations.Registry
+	signatures signatures.Cache
+	signQueue  queue.Queue
+	Config     models.Config
+	trustKey   string
+	peerHome   string
+	// guards P2pId and subscribed, they are set at startup and read by the api
+	stateLock  sync.RWMutex
+	P2pId      string
+	subscribed bool
 }
 
+const (
+	defaultSignQueueTimeout = 120
+)
+
 var logging *zap.SugaredLogger
 
 //	Constructor of an app
 func NewRosenTss(connection network.Connection, storage storage.Storage, config models.Config, trustKey string) _interface.RosenTss {
 	logging = logger.NewSugar("app")
 	return &rosenTss{
-		ChannelMap:         make(map[string]chan models.GossipMessage),
-		KeygenOperationMap: make(map[string]_interface.KeygenOperation),
-		SignOperationMap:   make(map[string]_interface.SignOperation),
-		eddsaMetaData:      models.MetaData{},
-		ecdsaMetaData:      models.MetaData{},
-		storage:            storage,
-		connection:         connection,
-		trustKey:           trustKey,
-		Config:             config,
+		manager:    newOperationManager(),
+		metaData:   make(map[string]models.MetaData),
+		storage:    storage,
+		connection: connection,
+		registry:   operations.NewRegistry(config),
+		signatures: signatures.NewCache(config),
+		signQueue:  queue.NewQueue(config),
+		trustKey:   trustKey,
+		Config:     config,
 	}
 }
 
 func (r *rosenTss) errorCallBackCall(data interface{}, callBackUrl string) {
-	callbackErr := r.GetConnection().CallBack(callBackUrl, data)
+	callbackErr := r.CallBack(callBackUrl, data)
 	if callbackErr != nil {
 		logging.Error(callbackErr)
 	}
 }
 
-func (r *rosenTss) timeOutGoRoutine(operationName string, operationTimeout int, messageId string, errorCh chan error) {
+//	returns status of the failure callback, a cancelled operation is reported as cancelled
+func failureStatus(err error) string {
+	if err.Error() == models.OperationCancelledError {
+		return models.OperationCancelled
+	}
+	return "fail"
+}
+
+//	stops the operation on timeout or cancellation, by sending the error to the operation
+func (r *rosenTss) timeOutGoRoutine(operationName string, operationTimeout int, messageId string, operationId string, errorCh chan error) {
 	go func() {
+		var err error
 		timeout := time.After(time.Second * time.Duration(operationTimeout))
-		for {
-			select {
-			case <-timeout:
-				if _, ok := r.ChannelMap[messageId]; ok {
-					err := fmt.Errorf("%s operation timeout", operationName)
-					errorCh <- err
-					time.After(time.Second * 4)
-					close(r.ChannelMap[messageId])
-				}
-				return
-			}
+		select {
+		case <-timeout:
+			r.registry.Timeout(operationId)
+			err = fmt.Errorf("%s operation timeout", operationName)
+		case <-r.registry.Cancelled(operationId):
+			err = fmt.Errorf(models.OperationCancelledError)
+		case <-r.registry.Done(operationId):
+			return
+		}
+		select {
+		case errorCh <- err:
+		case <-r.registry.Done(operationId):
 		}
 	}()
 }
 
 // StartNewKeygen starts keygen scenario for app based on given protocol.
-func (r *rosenTss) StartNewKeygen(keygenMessage models.KeygenMessage) error {
+func (r *rosenTss) StartNewKeygen(keygenMessage models.KeygenMessage) (string, error) {
 	logging.Info("Starting New keygen process")
 
-	path := fmt.Sprintf("%s/%s/%s", r.GetPeerHome(), keygenMessage.Crypto, "keygen_data.json")
-	if _, err := os.Stat(path); err == nil {
-		return fmt.Errorf(models.KeygenFileExistError)
+	keyId, err := utils.CheckKeyId(keygenMessage.KeyId)
+	if err != nil {
+		return "", err
+	}
+	keygenMessage.KeyId = keyId
+
+	exists, err := r.keyExists(keygenMessage.Crypto, keyId)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf(models.KeygenFileExistError)
 	}
 
-	messageId := fmt.Sprintf("%s%s", keygenMessage.Crypto, "Keygen")
-	_, ok := r.ChannelMap[messageId]
-	if !ok {
-		messageCh := make(chan models.GossipMessage, 100)
-		r.ChannelMap[messageId] = messageCh
-		logging.Infof("creating new channel in StartNewKeygen: %v", messageId)
-	} else {
-		return fmt.Errorf(models.DuplicatedMessageIdError)
+	messageId := utils.KeyMessageId(fmt.Sprintf("%s%s", keygenMessage.Crypto, "Keygen"), keyId)
+	channel, err := r.manager.addChannel(messageId)
+	if err != nil {
+		return "", err
 	}
+	logging.Infof("creating new channel in StartNewKeygen: %v", messageId)
 
 	var operation _interface.KeygenOperation
 	switch keygenMessage.Crypto {
@@ -103,54 +144,67 @@ func (r *rosenTss) StartNewKeygen(keygenMessage models.KeygenMessage) error {
 	case models.ECDSA:
 		operation = ecdsaKeygen.NewKeygenECDSAOperation(keygenMessage)
 	default:
-		return fmt.Errorf(models.WrongCryptoProtocolError)
+		r.manager.removeChannel(messageId)
+		return "", fmt.Errorf(models.WrongCryptoProtocolError)
 	}
-	channelId := operation.GetClassName()
-	r.KeygenOperationMap[channelId] = operation
-
-	errorCh := make(chan error)
-	r.timeOutGoRoutine(operation.GetClassName(), keygenMessage.OperationTimeout, messageId, errorCh)
 
-	err := operation.Init(r, keygenMessage.P2PIDs)
+	err = operation.Init(r, keygenMessage.P2PIDs)
 	if err != nil {
-		return err
+		r.manager.removeChannel(messageId)
+		return "", err
 	}
+
+	channelId := messageId
+	r.manager.addKeygen(channelId, operation)
+	operationId := r.registry.Add("keygen", keygenMessage.Crypto, keyId, messageId)
+
+	errorCh := make(chan error)
+	r.timeOutGoRoutine(operation.GetClassName(), keygenMessage.OperationTimeout, messageId, operationId, errorCh)
+
 	go func() {
 		logging.Infof("calling start action for %s keygen", keygenMessage.Crypto)
-		err = operation.StartAction(r, r.ChannelMap[messageId], errorCh)
+		r.registry.SetState(operationId, models.OperationWaitingForPeers)
+		err := operation.StartAction(r, channel.messageCh, errorCh)
 		if err != nil {
 			logging.Errorf("an error occurred in %s keygen action, err: %+v", keygenMessage.Crypto, err)
 			data := models.FailKeygenData{
-				Error:  err.Error(),
-				Status: "fail",
+				KeyId:    keyId,
+				Error:    err.Error(),
+				Status:   failureStatus(err),
+				Progress: r.timeoutProgress(operationId),
+				Failure:  r.operationFailure(operationId, err),
 			}
 			r.errorCallBackCall(data, keygenMessage.CallBackUrl)
 		}
-		r.deleteInstance("keygen", messageId, channelId, errorCh)
+		r.registry.Finish(operationId, err)
+		r.deleteInstance("keygen", messageId, channelId)
 		logging.Infof("end of %s keygen action", keygenMessage.Crypto)
 		return
 	}()
 
-	return nil
+	return operationId, nil
 }
 
 //	starts sign scenario for app based on given protocol.
-func (r *rosenTss) StartNewSign(signMessage models.SignMessage) error {
+func (r *rosenTss) StartNewSign(signMessage models.SignMessage) (string, error) {
 	logging.Info("Starting New Sign process")
+	keyId, err := utils.CheckKeyId(signMessage.KeyId)
+	if err != nil {
+		return "", err
+	}
+	signMessage.KeyId = keyId
+
 	msgBytes, _ := utils.HexDecoder(signMessage.Message)
 	signDataBytes := blake2b.Sum256(msgBytes)
 	signDataHash := utils.HexEncoder(signDataBytes[:])
 	logging.Infof("encoded sign data: %v", signDataHash)
 
-	messageId := fmt.Sprintf("%s%s", signMessage.Crypto, signDataHash)
-	_, ok := r.ChannelMap[messageId]
-	if !ok {
-		messageCh := make(chan models.GossipMessage, 100)
-		r.ChannelMap[messageId] = messageCh
-		logging.Infof("new communication channel for signning process: %v", messageId)
-	} else {
-		return fmt.Errorf(models.DuplicatedMessageIdError)
+	messageId := utils.KeyMessageId(fmt.Sprintf("%s%s", signMessage.Crypto, signDataHash), keyId)
+	channel, err := r.manager.addChannel(messageId)
+	if err != nil {
+		return "", err
 	}
+	logging.Infof("new communication channel for signning process: %v", messageId)
 
 	var operation _interface.SignOperation
 	switch signMessage.Crypto {
@@ -158,42 +212,270 @@ func (r *rosenTss) StartNewSign(signMessage models.SignMessage) error {
 		operation = eddsaSign.NewSignEDDSAOperation(signMessage)
 	case models.ECDSA:
 		if len(signMessage.DerivationPath) == 0 {
-			return fmt.Errorf(models.WrongDerivationPathError)
+			r.manager.removeChannel(messageId)
+			return "", fmt.Errorf(models.WrongDerivationPathError)
 		}
 		operation = ecdsaSign.NewSignECDSAOperation(signMessage)
 	default:
-		return fmt.Errorf(models.WrongCryptoProtocolError)
+		r.manager.removeChannel(messageId)
+		return "", fmt.Errorf(models.WrongCryptoProtocolError)
 	}
 
-	channelId := fmt.Sprintf("%s%s%s", operation.GetClassName(), signMessage.ChainCode, messageId)
-	r.SignOperationMap[channelId] = operation
+	err = operation.Init(r, signMessage.Peers)
+	if err != nil {
+		r.manager.removeChannel(messageId)
+		return "", err
+	}
 
-	errorCh := make(chan error)
-	r.timeOutGoRoutine(operation.GetClassName(), signMessage.OperationTimeout, messageId, errorCh)
+	channelId := fmt.Sprintf("%s%s%s", operation.GetClassName(), signMessage.ChainCode, messageId)
+	r.manager.addSign(channelId, operation)
 
-	err := operation.Init(r, signMessage.Peers)
+	// the sign is registered after the queue admits it, so rejected signs are not listed as operations. items of a
+	// batch take the places reserved for the batch
+	operationId := operations.NewId()
+	admitted, err := r.signQueue.Add(signMessage.Crypto, operationId, signMessage.Priority, signMessage.BatchId != "")
 	if err != nil {
-		return err
+		r.deleteInstance("sign", messageId, channelId)
+		return "", err
 	}
+	r.registry.Register(operationId, "sign", signMessage.Crypto, keyId, messageId)
+	if signMessage.BatchId != "" {
+		r.setBatchOperationId(signMessage, operationId)
+	}
+
 	go func() {
-		logging.Infof("calling start action for %s sign", signMessage.Crypto)
-		err = operation.StartAction(r, r.ChannelMap[messageId], errorCh)
+		// messages of peers are kept in the channel while the sign is queued
+		err := r.waitForAdmission(signMessage, operationId, admitted)
+		if err == nil {
+			errorCh := make(chan error)
+			r.timeOutGoRoutine(operation.GetClassName(), signMessage.OperationTimeout, messageId, operationId, errorCh)
+			logging.Infof("calling start action for %s sign", signMessage.Crypto)
+			r.registry.SetState(operationId, models.OperationWaitingForPeers)
+			err = operation.StartAction(r, channel.messageCh, errorCh)
+		}
+		r.signQueue.Done(signMessage.Crypto, operationId)
 		if err != nil {
 			logging.Errorf("an error occurred in %s sign action, err: %+v", signMessage.Crypto, err)
-			data := models.SignData{
-				Message:  signMessage.Message,
-				Error:    err.Error(),
-				TrustKey: r.trustKey,
-				Status:   "fail",
+			// failures of a batch item are sent in the callback of the batch
+			if signMessage.BatchId == "" {
+				r.errorCallBackCall(r.signFailure(signMessage, operationId, err), signMessage.CallBackUrl)
 			}
-			r.errorCallBackCall(data, signMessage.CallBackUrl)
 		}
-		r.deleteInstance("sign", messageId, channelId, errorCh)
+		if err == nil {
+			r.cacheSignature(signMessage, operationId)
+		}
+		r.registry.Finish(operationId, err)
+		r.deleteInstance("sign", messageId, channelId)
+		if signMessage.BatchId != "" {
+			r.signBatchItemFinished(signMessage, operationId, err)
+		}
 		logging.Infof("end of %s sign action", signMessage.Crypto)
 		return
 	}()
 
-	return nil
+	return operationId, nil
+}
+
+//	waits until the sign queue admits the sign, the wait is limited by the queue timeout of the sign
+func (r *rosenTss) waitForAdmission(signMessage models.SignMessage, operationId string, admitted <-chan struct{}) error {
+	queueTimeout := signMessage.QueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = r.Config.SignQueueTimeout
+	}
+	if queueTimeout <= 0 {
+		queueTimeout = defaultSignQueueTimeout
+	}
+	select {
+	case <-admitted:
+		return nil
+	case <-time.After(time.Second * time.Duration(queueTimeout)):
+		return fmt.Errorf(models.SignQueueTimeoutError)
+	case <-r.registry.Cancelled(operationId):
+		return fmt.Errorf(models.OperationCancelledError)
+	}
+}
+
+//	returns the failure result of the sign
+func (r *rosenTss) signFailure(signMessage models.SignMessage, operationId string, err error) models.SignData {
+	return models.SignData{
+		Message:        signMessage.Message,
+		KeyId:          signMessage.KeyId,
+		DerivationPath: signMessage.DerivationPath,
+		Error:          err.Error(),
+		TrustKey:       r.GetCallbackTrustKey(),
+		Status:         failureStatus(err),
+		Progress:       r.timeoutProgress(operationId),
+		Failure:        r.operationFailure(operationId, err),
+	}
+}
+
+//	returns progress of the operation if it is timed out, so its failure shows the round and the stalled peers
+func (r *rosenTss) timeoutProgress(operationId string) *models.OperationProgress {
+	operation, err := r.registry.Get(operationId)
+	if err != nil || operation.State != models.OperationTimedOut {
+		return nil
+	}
+	return operation.Progress
+}
+
+//	returns the failure of the operation for its callback, an error of tss-lib is a protocol abort with the round and
+//	the culprits found by the party, a timed-out operation reports its round and the peers it waits for and other
+//	errors are local. cancelled operations are not failed, so they have no failure
+func (r *rosenTss) operationFailure(operationId string, err error) *models.OperationFailure {
+	if err.Error() == models.OperationCancelledError {
+		return nil
+	}
+	failure := &models.OperationFailure{
+		Kind:       models.FailureLocalError,
+		Culprits:   make([]models.Peer, 0),
+		WaitingFor: make([]string, 0),
+	}
+	if operation, getErr := r.registry.Get(operationId); getErr == nil {
+		if operation.State == models.OperationTimedOut {
+			failure.Kind = models.FailureTimeout
+		}
+		if operation.Progress != nil {
+			failure.Round = operation.Progress.Round
+			if operation.Progress.WaitingFor != nil {
+				failure.WaitingFor = operation.Progress.WaitingFor
+			}
+		}
+	}
+
+	var tssErr *tss.Error
+	if errors.As(err, &tssErr) {
+		failure.Kind = models.FailureProtocolAbort
+		failure.Round = tssErr.Round()
+		for _, culprit := range tssErr.Culprits() {
+			if culprit == nil {
+				continue
+			}
+			failure.Culprits = append(failure.Culprits, models.Peer{
+				ShareID: culprit.KeyInt().String(),
+				P2PID:   culprit.Id,
+			})
+		}
+	}
+	return failure
+}
+
+//	caches the signature of the finished sign operation
+func (r *rosenTss) cacheSignature(signMessage models.SignMessage, operationId string) {
+	operation, err := r.registry.Get(operationId)
+	if err != nil {
+		return
+	}
+	signData, ok := operation.Result.(models.SignData)
+	if !ok {
+		return
+	}
+	hash := utils.SignatureHash(signMessage.Crypto, signMessage.KeyId, signMessage.Message, signMessage.ChainCode, signMessage.DerivationPath)
+	r.signatures.Add(hash, signMessage.Crypto, signMessage.ChainCode, signData)
+}
+
+//	returns the cached signature of the sign request,
+//	SignatureNotFoundError is returned if the message is not signed yet
+func (r *rosenTss) CachedSignature(signMessage models.SignMessage) (models.SignatureRecord, error) {
+	keyId, err := utils.CheckKeyId(signMessage.KeyId)
+	if err != nil {
+		return models.SignatureRecord{}, err
+	}
+	hash := utils.SignatureHash(signMessage.Crypto, keyId, signMessage.Message, signMessage.ChainCode, signMessage.DerivationPath)
+	return r.signatures.Get(hash)
+}
+
+//	sends the cached signature to the callback url again
+func (r *rosenTss) ResendSignature(record models.SignatureRecord, callBackUrl string) error {
+	logging.Infof("message %s is signed already, sending the cached signature", record.SignData.Message)
+	signData := record.SignData
+	signData.TrustKey = r.GetCallbackTrustKey()
+	return r.CallBack(callBackUrl, signData)
+}
+
+//	returns the cached signature of the hash
+func (r *rosenTss) GetSignature(hash string) (models.SignatureRecord, error) {
+	return r.signatures.Get(hash)
+}
+
+// StartNewRegroup starts regroup scenario for app based on given protocol.
+func (r *rosenTss) StartNewRegroup(regroupMessage models.RegroupMessage) (string, error) {
+	logging.Info("Starting New regroup process")
+
+	keyId, err := utils.CheckKeyId(regroupMessage.KeyId)
+	if err != nil {
+		return "", err
+	}
+	regroupMessage.KeyId = keyId
+
+	messageId := utils.KeyMessageId(fmt.Sprintf("%s%s", regroupMessage.Crypto, "Regroup"), keyId)
+	channel, err := r.manager.addChannel(messageId)
+	if err != nil {
+		return "", err
+	}
+	logging.Infof("creating new channel in StartNewRegroup: %v", messageId)
+
+	var operation _interface.RegroupOperation
+	switch regroupMessage.Crypto {
+	case models.EDDSA:
+		operation = eddsaRegroup.NewRegroupEDDSAOperation(regroupMessage)
+	case models.ECDSA:
+		operation = ecdsaRegroup.NewRegroupECDSAOperation(regroupMessage)
+	default:
+		r.manager.removeChannel(messageId)
+		return "", fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+
+	err = operation.Init(r, regroupMessage.OldPeers, regroupMessage.NewP2PIDs)
+	if err != nil {
+		r.manager.removeChannel(messageId)
+		return "", err
+	}
+
+	channelId := messageId
+	r.manager.addRegroup(channelId, operation)
+	operationId := r.registry.Add("regroup", regroupMessage.Crypto, keyId, messageId)
+
+	errorCh := make(chan error)
+	r.timeOutGoRoutine(operation.GetClassName(), regroupMessage.OperationTimeout, messageId, operationId, errorCh)
+
+	go func() {
+		logging.Infof("calling start action for %s regroup", regroupMessage.Crypto)
+		r.registry.SetState(operationId, models.OperationWaitingForPeers)
+		err := operation.StartAction(r, channel.messageCh, errorCh)
+		if err != nil {
+			logging.Errorf("an error occurred in %s regroup action, err: %+v", regroupMessage.Crypto, err)
+			data := models.RegroupData{
+				KeyId:    keyId,
+				Error:    err.Error(),
+				Status:   failureStatus(err),
+				Progress: r.timeoutProgress(operationId),
+				Failure:  r.operationFailure(operationId, err),
+			}
+			r.errorCallBackCall(data, regroupMessage.CallBackUrl)
+		} else {
+			r.resetSignData(regroupMessage.Crypto, keyId)
+		}
+		r.registry.Finish(operationId, err)
+		r.deleteInstance("regroup", messageId, channelId)
+		logging.Infof("end of %s regroup action", regroupMessage.Crypto)
+		return
+	}()
+
+	return operationId, nil
+}
+
+//	drops the loaded keygen data of the key in sign handlers, so the next sign uses the stored data,
+//	and the cached signatures of the key, so a repeated sign is not answered with a signature of the old key
+func (r *rosenTss) resetSignData(crypto string, keyId string) {
+	switch crypto {
+	case models.EDDSA:
+		eddsaSign.ResetData(keyId)
+	case models.ECDSA:
+		ecdsaSign.ResetData(keyId)
+	}
+	if r.signatures != nil {
+		r.signatures.Purge(crypto, keyId)
+	}
 }
 
 //	handles the receiving message from message route
@@ -202,6 +484,7 @@ func (r *rosenTss) MessageHandler(message models.Message) error {
 	msgBytes := []byte(message.Message)
 	gossipMsg := models.GossipMessage{}
 	err := json.Unmarshal(msgBytes, &gossipMsg)
+	metrics.MessageReceived(len(msgBytes), err)
 	if err != nil {
 		return err
 	}
@@ -209,25 +492,31 @@ func (r *rosenTss) MessageHandler(message models.Message) error {
 	logging.Infof("callback route called. recevied a message with messageId %+v from: %+v", gossipMsg.MessageId, gossipMsg.SenderId)
 	logging.Debugf("message info is: %+v", gossipMsg)
 
-	// handling recover in case the channel is closed but not removed from the list yet, and there is a message to send on that
-	send := func(c chan models.GossipMessage, t models.GossipMessage) {
-		defer func() {
-			if x := recover(); x != nil {
-				logging.Warnf("unable to send: %v", x)
-			}
-		}()
-		c <- t
+	// dropping messages which are not signed by the registered identity of their sender or can not be decrypted
+	authenticated, err := r.authenticate(gossipMsg)
+	if err != nil {
+		logging.Warnf("message %+v from %+v dropped, err: %+v", gossipMsg.MessageId, gossipMsg.SenderId, err)
+		return err
 	}
+	gossipMsg = authenticated
 
 	// wait for not found channels
 	go func() {
 		for i, start := 0, time.Now(); ; i++ {
 			if time.Since(start) > time.Second*time.Duration(r.Config.MessageTimeout) {
 				logging.Warnf("message timeout, channel not found: %+v", gossipMsg.MessageId)
+				metrics.MessageDropped(metrics.DroppedChannelNotFound)
 				break
 			}
-			if _, ok := r.ChannelMap[gossipMsg.MessageId]; ok {
-				send(r.ChannelMap[gossipMsg.MessageId], gossipMsg)
+			if channel, ok := r.manager.getChannel(gossipMsg.MessageId); ok {
+				select {
+				case channel.messageCh <- gossipMsg:
+					r.registry.Running(gossipMsg.MessageId)
+					r.registry.MessageReceived(gossipMsg.MessageId, gossipMsg.SenderId, len(gossipMsg.Message))
+				case <-channel.done:
+					logging.Warnf("operation finished, message dropped: %+v", gossipMsg.MessageId)
+					metrics.MessageDropped(metrics.DroppedOperationFinished)
+				}
 				break
 			}
 			time.Sleep(time.Millisecond * time.Duration(r.Config.WriteMsgRetryTime))
@@ -236,6 +525,74 @@ func (r *rosenTss) MessageHandler(message models.Message) error {
 	return nil
 }
 
+//	verifies signature of the message, decrypts point-to-point messages and checks its sender is the sender of
+//	the party message, returns the message with the plain party message
+func (r *rosenTss) authenticate(gossipMsg models.GossipMessage) (models.GossipMessage, error) {
+	if r.identity != nil {
+		err := r.identity.Verify(gossipMsg)
+		if err != nil {
+			if err.Error() == models.UnknownPeerError {
+				metrics.MessageDropped(metrics.DroppedUnknownPeer)
+			} else {
+				metrics.MessageDropped(metrics.DroppedInvalidSignature)
+			}
+			return models.GossipMessage{}, err
+		}
+	}
+
+	switch {
+	case gossipMsg.Encrypted:
+		if r.identity == nil {
+			metrics.MessageDropped(metrics.DroppedDecryptionFailed)
+			return models.GossipMessage{}, fmt.Errorf(models.MessageDecryptionError)
+		}
+		decrypted, err := r.identity.Decrypt(gossipMsg)
+		if err != nil {
+			metrics.MessageDropped(metrics.DroppedDecryptionFailed)
+			return models.GossipMessage{}, err
+		}
+		gossipMsg = decrypted
+	case gossipMsg.ReceiverId != "" && r.identity != nil && r.identity.Authenticated():
+		metrics.MessageDropped(metrics.DroppedNotEncrypted)
+		return models.GossipMessage{}, fmt.Errorf(models.MessageNotEncryptedError)
+	}
+
+	msgBytes, err := utils.HexDecoder(gossipMsg.Message)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	partyMsg := models.PartyMessage{}
+	err = json.Unmarshal(msgBytes, &partyMsg)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	if partyMsg.GetFrom == nil || partyMsg.GetFrom.Id != gossipMsg.SenderId {
+		metrics.MessageDropped(metrics.DroppedSenderMismatch)
+		return models.GossipMessage{}, fmt.Errorf(models.MessageSenderError)
+	}
+	return gossipMsg, nil
+}
+
+//	encrypts point-to-point messages to the identity of the receiver if identities of peers are registered,
+//	signs the message with the identity key of the peer and publishes it to p2p
+func (r *rosenTss) Publish(message models.GossipMessage) error {
+	if r.identity != nil {
+		var err error
+		if message.ReceiverId != "" && r.identity.Authenticated() {
+			message, err = r.identity.Encrypt(message)
+			if err != nil {
+				return err
+			}
+		}
+		message, err = r.identity.Sign(message)
+		if err != nil {
+			return err
+		}
+	}
+	r.registry.MessageSent(message.MessageId, len(message.Message))
+	return r.GetConnection().Publish(message)
+}
+
 //	returns the storage
 func (r *rosenTss) GetStorage() storage.Storage {
 	return r.storage
@@ -246,6 +603,62 @@ func (r *rosenTss) GetConnection() network.Connection {
 	return r.connection
 }
 
+//	returns the operation registry
+func (r *rosenTss) GetRegistry() operations.Registry {
+	return r.registry
+}
+
+//	returns the sign queue
+func (r *rosenTss) GetSignQueue() queue.Queue {
+	return r.signQueue
+}
+
+//	sets the ecdsa pre-params pool
+func (r *rosenTss) SetPreParams(preParams preparams.PreParams) {
+	r.preParams = preParams
+}
+
+//	returns the ecdsa pre-params pool
+func (r *rosenTss) GetPreParams() preparams.PreParams {
+	return r.preParams
+}
+
+//	sets the callback outbox
+func (r *rosenTss) SetOutbox(outbox outbox.Outbox) {
+	r.outbox = outbox
+}
+
+//	returns the callback outbox
+func (r *rosenTss) GetOutbox() outbox.Outbox {
+	return r.outbox
+}
+
+//	sends the callback through the outbox, so it is retried until it is delivered,
+//	it is sent directly if there is no outbox
+func (r *rosenTss) CallBack(url string, data interface{}) error {
+	if r.outbox == nil {
+		return r.GetConnection().CallBack(url, data)
+	}
+	return r.outbox.Send(url, data)
+}
+
+//	sets the identity of the peer
+func (r *rosenTss) SetIdentity(identity identity.Identity) {
+	r.identity = identity
+}
+
+//	returns the identity of the peer with its p2pId
+func (r *rosenTss) GetIdentity() models.PeerIdentity {
+	peerIdentity := models.PeerIdentity{
+		P2pId: r.GetP2pId(),
+	}
+	if r.identity != nil {
+		peerIdentity.SigningKey = r.identity.SigningKey()
+		peerIdentity.EncryptionKey = r.identity.EncryptionKey()
+	}
+	return peerIdentity
+}
+
 //	setups peer home address and creates that
 func (r *rosenTss) SetPeerHome(homeAddress string) error {
 	logging.Info("setting up home directory")
@@ -255,7 +668,13 @@ func (r *rosenTss) SetPeerHome(homeAddress string) error {
 		return err
 	}
 	r.peerHome = absAddress
-	return nil
+
+	// restoring operation history and cached signatures of the storage
+	err = r.registry.Restore(r.storage, absAddress)
+	if err != nil {
+		return err
+	}
+	return r.signatures.Restore(r.storage, absAddress)
 }
 
 //	returns the peer's home
@@ -263,95 +682,357 @@ func (r *rosenTss) GetPeerHome() string {
 	return r.peerHome
 }
 
-//	setting ups metadata from given file in the home directory
-func (r *rosenTss) SetMetaData(meta models.MetaData, crypto string) error {
+//	returns the key of meta data of a crypto key
+func metaDataKey(crypto string, keyId string) string {
+	return fmt.Sprintf("%s/%s", crypto, keyId)
+}
+
+//	setting ups metadata of the key from given file in the home directory
+func (r *rosenTss) SetMetaData(meta models.MetaData, crypto string, keyId string) error {
+	if crypto != models.EDDSA && crypto != models.ECDSA {
+		return fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	r.metaLock.Lock()
+	defer r.metaLock.Unlock()
+	if (meta == models.MetaData{}) {
+		delete(r.metaData, metaDataKey(crypto, keyId))
+		return nil
+	}
+	r.metaData[metaDataKey(crypto, keyId)] = meta
+	return nil
+}
+
+//	returns peer's meta data of the key
+func (r *rosenTss) GetMetaData(crypto string, keyId string) (models.MetaData, error) {
+	r.metaLock.RLock()
+	defer r.metaLock.RUnlock()
+	meta, ok := r.metaData[metaDataKey(crypto, keyId)]
 	switch crypto {
 	case models.EDDSA:
-		r.eddsaMetaData = meta
-		return nil
+		if !ok {
+			return meta, fmt.Errorf(models.EDDSANoMetaDataFoundError)
+		}
+		return meta, nil
 	case models.ECDSA:
-		r.ecdsaMetaData = meta
-		return nil
+		if !ok {
+			return meta, fmt.Errorf(models.ECDSANoMetaDataFoundError)
+		}
+		return meta, nil
 	default:
-		return fmt.Errorf(models.WrongCryptoProtocolError)
+		return models.MetaData{}, fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+}
+
+//	returns true if keygen data of the key is stored
+func (r *rosenTss) keyExists(crypto string, keyId string) (bool, error) {
+	keyIds, err := r.GetStorage().ListKeys(r.GetPeerHome(), crypto)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range keyIds {
+		if id == keyId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//	returns the stored keys of the crypto with their public key and meta data
+func (r *rosenTss) GetKeys(crypto string) ([]models.KeyInfo, error) {
+	if crypto != models.EDDSA && crypto != models.ECDSA {
+		return nil, fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	keyIds, err := r.GetStorage().ListKeys(r.GetPeerHome(), crypto)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]models.KeyInfo, 0, len(keyIds))
+	for _, keyId := range keyIds {
+		key := models.KeyInfo{
+			Crypto: crypto,
+			KeyId:  keyId,
+		}
+		switch crypto {
+		case models.EDDSA:
+			data, _, err := r.GetStorage().LoadEDDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+			if err != nil {
+				return nil, err
+			}
+			pkX, pkY := data.KeygenData.EDDSAPub.X(), data.KeygenData.EDDSAPub.Y()
+			key.PubKey = utils.HexEncoder(utils.GetPKFromEDDSAPub(pkX, pkY))
+			key.ShareID = data.KeygenData.ShareID.String()
+			key.PeersCount = data.MetaData.PeersCount
+			key.Threshold = data.MetaData.Threshold
+		case models.ECDSA:
+			data, _, err := r.GetStorage().LoadECDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+			if err != nil {
+				return nil, err
+			}
+			pkX, pkY := data.KeygenData.ECDSAPub.X(), data.KeygenData.ECDSAPub.Y()
+			key.PubKey = utils.HexEncoder(utils.GetPKFromECDSAPub(pkX, pkY))
+			key.ShareID = data.KeygenData.ShareID.String()
+			key.PeersCount = data.MetaData.PeersCount
+			key.Threshold = data.MetaData.Threshold
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+//	checks integrity of keygen data of the stored keys of the crypto, keys of all cryptos are checked if it is empty
+func (r *rosenTss) CheckKeys(crypto string) ([]models.KeyCheck, error) {
+	cryptos := []string{models.EDDSA, models.ECDSA}
+	if crypto != "" {
+		if crypto != models.EDDSA && crypto != models.ECDSA {
+			return nil, fmt.Errorf(models.WrongCryptoProtocolError)
+		}
+		cryptos = []string{crypto}
+	}
+	checks := make([]models.KeyCheck, 0)
+	for _, crypto := range cryptos {
+		keyIds, err := r.GetStorage().ListKeys(r.GetPeerHome(), crypto)
+		if err != nil {
+			return nil, err
+		}
+		for _, keyId := range keyIds {
+			checks = append(checks, r.checkKey(crypto, keyId))
+		}
 	}
+	return checks, nil
 }
 
-//	returns peer's meta data
-func (r *rosenTss) GetMetaData(crypto string) (models.MetaData, error) {
+//	loads and checks keygen data of a key, the result is logged
+func (r *rosenTss) checkKey(crypto string, keyId string) models.KeyCheck {
+	check := models.KeyCheck{
+		Crypto:    crypto,
+		KeyId:     keyId,
+		CheckedAt: time.Now(),
+	}
 	switch crypto {
 	case models.EDDSA:
-		if (r.eddsaMetaData != models.MetaData{}) {
-			return r.eddsaMetaData, nil
+		data, _, err := r.GetStorage().LoadEDDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+		if err != nil {
+			check.Errors = []string{err.Error()}
 		} else {
-			return r.eddsaMetaData, fmt.Errorf(models.EDDSANoMetaDataFoundError)
+			check.Errors = integrity.CheckEDDSA(data)
 		}
 	case models.ECDSA:
-		if (r.ecdsaMetaData != models.MetaData{}) {
-			return r.ecdsaMetaData, nil
+		data, _, err := r.GetStorage().LoadECDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+		if err != nil {
+			check.Errors = []string{err.Error()}
 		} else {
-			return r.ecdsaMetaData, fmt.Errorf(models.ECDSANoMetaDataFoundError)
+			check.Errors, check.Warnings = integrity.CheckECDSA(data)
+		}
+	}
+	check.Valid = len(check.Errors) == 0
+	if len(check.Warnings) > 0 {
+		logging.Warnf("%s key %s is partially checked: %s", crypto, keyId, strings.Join(check.Warnings, ", "))
+	}
+	if check.Valid {
+		logging.Infof("%s key %s passed the integrity check", crypto, keyId)
+	} else {
+		logging.Errorf("%s key %s failed the integrity check: %s", crypto, keyId, strings.Join(check.Errors, ", "))
+	}
+	return check
+}
+
+//	moves the stored key to the archive, so it is no longer used, and a new keygen can be done with its keyId
+func (r *rosenTss) ArchiveKey(crypto string, keyId string) error {
+	if crypto != models.EDDSA && crypto != models.ECDSA {
+		return fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	if operationName := r.runningOperation(crypto, keyId); operationName != "" {
+		return fmt.Errorf("%s "+models.OperationIsRunningError, operationName)
+	}
+
+	_, err := r.GetStorage().ArchiveKey(r.GetPeerHome(), crypto, keyId)
+	if err != nil {
+		return err
+	}
+	err = r.SetMetaData(models.MetaData{}, crypto, keyId)
+	if err != nil {
+		return err
+	}
+	r.resetSignData(crypto, keyId)
+	return nil
+}
+
+//	returns name of an operation running on the crypto key, empty if there is none
+func (r *rosenTss) runningOperation(crypto string, keyId string) string {
+	var running []interface {
+		GetClassName() string
+		GetKeyId() string
+	}
+	for _, operation := range r.manager.keygenOperations() {
+		running = append(running, operation)
+	}
+	for _, operation := range r.manager.signOperations() {
+		running = append(running, operation)
+	}
+	for _, operation := range r.manager.regroupOperations() {
+		running = append(running, operation)
+	}
+	for _, operation := range running {
+		if strings.HasPrefix(operation.GetClassName(), crypto) && operation.GetKeyId() == keyId {
+			return operation.GetClassName()
+		}
+	}
+	return ""
+}
+
+//	derives the public key of the stored keygen data of the key for chain code and derivation path
+func (r *rosenTss) GetDerivedPubKey(crypto string, keyId string, chainCode string, path []uint32) (models.PubKeyData, error) {
+	var pubKeyData models.PubKeyData
+	switch crypto {
+	case models.EDDSA:
+		data, _, err := r.GetStorage().LoadEDDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+		if err != nil {
+			return models.PubKeyData{}, err
+		}
+		pubKeyData, err = eddsaSign.DerivePubKey(data.KeygenData.EDDSAPub, chainCode, path)
+		if err != nil {
+			return models.PubKeyData{}, err
+		}
+	case models.ECDSA:
+		data, _, err := r.GetStorage().LoadECDSAKeygen(r.GetPeerHome(), r.GetP2pId(), keyId)
+		if err != nil {
+			return models.PubKeyData{}, err
+		}
+		pubKeyData, err = ecdsaSign.DerivePubKey(data.KeygenData.ECDSAPub, chainCode, path)
+		if err != nil {
+			return models.PubKeyData{}, err
 		}
 	default:
-		return models.MetaData{}, fmt.Errorf(models.WrongCryptoProtocolError)
+		return models.PubKeyData{}, fmt.Errorf(models.WrongCryptoProtocolError)
 	}
+	pubKeyData.Crypto = crypto
+	pubKeyData.KeyId = keyId
+	pubKeyData.ChainCode = chainCode
+	pubKeyData.DerivationPath = path
+	return pubKeyData, nil
 }
 
 //	returns list of operations
 func (r *rosenTss) GetKeygenOperations() map[string]_interface.KeygenOperation {
-	return r.KeygenOperationMap
+	return r.manager.keygenOperations()
 }
 
 //	returns list of operations
 func (r *rosenTss) GetSignOperations() map[string]_interface.SignOperation {
-	return r.SignOperationMap
+	return r.manager.signOperations()
+}
+
+//	returns list of operations
+func (r *rosenTss) GetRegroupOperations() map[string]_interface.RegroupOperation {
+	return r.manager.regroupOperations()
 }
 
 //	removes operation and related channel from list
-func (r *rosenTss) deleteInstance(operationType string, messageId string, channelId string, errorCh chan error) {
+func (r *rosenTss) deleteInstance(operationType string, messageId string, channelId string) {
 	switch operationType {
 	case "keygen":
-		r.deleteKeygenInstance(messageId, channelId, errorCh)
+		r.deleteKeygenInstance(messageId, channelId)
 	case "sign":
-		r.deleteSignInstance(messageId, channelId, errorCh)
+		r.deleteSignInstance(messageId, channelId)
+	case "regroup":
+		r.deleteRegroupInstance(messageId, channelId)
 	}
 }
 
 //	removes operation and related channel for Keygen operation
-func (r *rosenTss) deleteKeygenInstance(messageId string, channelId string, errorCh chan error) {
-	operationName := r.KeygenOperationMap[channelId].GetClassName()
-	logging.Debugf("deleting %s for channelId %s and messageId %s for keygen operation", operationName, channelId, messageId)
-	delete(r.KeygenOperationMap, channelId)
-	delete(r.ChannelMap, messageId)
-	close(errorCh)
+func (r *rosenTss) deleteKeygenInstance(messageId string, channelId string) {
+	logging.Debugf("deleting channelId %s and messageId %s for keygen operation", channelId, messageId)
+	operationName := r.manager.removeKeygen(channelId).GetClassName()
+	r.manager.removeChannel(messageId)
 	logging.Infof("operation %s removed for channelId %s and messageId %s for keygen operation", operationName, channelId, messageId)
 }
 
 //	removes operation and related channel for sign Operation
-func (r *rosenTss) deleteSignInstance(messageId string, channelId string, errorCh chan error) {
-	operationName := r.SignOperationMap[channelId].GetClassName()
-	logging.Debugf("deleting %s for channelId %s and messageId %s for sign operation", operationName, channelId, messageId)
-	delete(r.SignOperationMap, channelId)
-	delete(r.ChannelMap, messageId)
-	close(errorCh)
+func (r *rosenTss) deleteSignInstance(messageId string, channelId string) {
+	logging.Debugf("deleting channelId %s and messageId %s for sign operation", channelId, messageId)
+	operationName := r.manager.removeSign(channelId).GetClassName()
+	r.manager.removeChannel(messageId)
 	logging.Infof("operation %s removed for channelId %s and messageId %s for sign operation", operationName, channelId, messageId)
 }
 
+//	removes operation and related channel for regroup Operation
+func (r *rosenTss) deleteRegroupInstance(messageId string, channelId string) {
+	logging.Debugf("deleting channelId %s and messageId %s for regroup operation", channelId, messageId)
+	operationName := r.manager.removeRegroup(channelId).GetClassName()
+	r.manager.removeChannel(messageId)
+	logging.Infof("operation %s removed for channelId %s and messageId %s for regroup operation", operationName, channelId, messageId)
+}
+
 //	set p2p to the variable
 func (r *rosenTss) SetP2pId() error {
 	p2pId, err := r.GetConnection().GetPeerId()
 	if err != nil {
 		return err
 	}
+	r.stateLock.Lock()
+	defer r.stateLock.Unlock()
 	r.P2pId = p2pId
 	return nil
 }
 
 //	get p2pId
 func (r *rosenTss) GetP2pId() string {
+	r.stateLock.RLock()
+	defer r.stateLock.RUnlock()
 	return r.P2pId
 }
 
+//	subscribes to p2p messages of the guard
+func (r *rosenTss) Subscribe(projectUrl string) error {
+	err := r.GetConnection().Subscribe(projectUrl)
+	if err != nil {
+		return err
+	}
+	r.stateLock.Lock()
+	defer r.stateLock.Unlock()
+	r.subscribed = true
+	return nil
+}
+
+//	returns readiness of the app, it is ready if it is subscribed to p2p, has its p2pId
+//	and meta data of all stored keys is loaded
+func (r *rosenTss) GetReadiness() models.Readiness {
+	r.stateLock.RLock()
+	subscribed := r.subscribed
+	r.stateLock.RUnlock()
+	readiness := models.Readiness{
+		Subscribed: subscribed,
+		P2pId:      r.GetP2pId(),
+		Keys:       make(map[string][]models.KeyStatus),
+	}
+	readiness.Ready = readiness.Subscribed && readiness.P2pId != ""
+	for _, crypto := range []string{models.EDDSA, models.ECDSA} {
+		keys := make([]models.KeyStatus, 0)
+		keyIds, err := r.GetStorage().ListKeys(r.GetPeerHome(), crypto)
+		if err != nil {
+			logging.Warnf("unable to list %s keys, err: %+v", crypto, err)
+			readiness.Ready = false
+		}
+		for _, keyId := range keyIds {
+			_, err := r.GetMetaData(crypto, keyId)
+			keys = append(keys, models.KeyStatus{KeyId: keyId, MetaData: err == nil})
+			if err != nil {
+				readiness.Ready = false
+			}
+		}
+		readiness.Keys[crypto] = keys
+	}
+	if r.GetPreParams() != nil {
+		readiness.PreParams = r.GetPreParams().Status()
+	}
+	for _, operation := range r.GetRegistry().List() {
+		if operation.FinishedAt == nil {
+			readiness.RunningOperations++
+		}
+	}
+	return readiness
+}
+
 //	get Config
 func (r *rosenTss) GetConfig() models.Config {
 	return r.Config
@@ -361,3 +1042,12 @@ func (r *rosenTss) GetConfig() models.Config {
 func (r *rosenTss) GetTrustKey() string {
 	return r.trustKey
 }
+
+//	returns the trust key to embed in sign callbacks, it is empty unless TSS_CALLBACK_TRUST_KEY is set,
+//	callbacks are authenticated with their hmac signature
+func (r *rosenTss) GetCallbackTrustKey() string {
+	if !r.Config.CallbackTrustKey {
+		return ""
+	}
+	return r.trustKey
+}
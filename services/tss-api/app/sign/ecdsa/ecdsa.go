@@ -3,6 +3,7 @@ package ecdsa
 import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/bnb-chain/tss-lib/v2/common"
@@ -19,7 +20,7 @@ import (
 	"rosen-bridge/tss-api/logger"
 	"rosen-bridge/tss-api/models"
 	"rosen-bridge/tss-api/utils"
-	"time"
+	"sync"
 )
 
 type operationECDSASign struct {
@@ -27,12 +28,16 @@ type operationECDSASign struct {
 }
 
 type handler struct {
+	lock      sync.RWMutex
+	keyId     string
 	savedData ecdsaKeygen.LocalPartySaveData
 	pID       *tss.PartyID
 }
 
 var logging *zap.SugaredLogger
-var ecdsaHandler handler
+var loggingOnce sync.Once
+var handlersLock sync.Mutex
+var ecdsaHandlers = make(map[string]*handler)
 
 //	- Initializes the ecdsa sign partyId and peers
 func (s *operationECDSASign) Init(rosenTss _interface.RosenTss, peers []models.Peer) error {
@@ -65,66 +70,87 @@ func (s *operationECDSASign) Init(rosenTss _interface.RosenTss, peers []models.P
 //	- creates end and out channel for party,
 //	- calls StartParty function of protocol
 //	- handles end channel and out channel in a go routine
-func (s *operationECDSASign) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error) {
+func (s *operationECDSASign) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error, done chan struct{}) error {
 	s.Logger.Info("creating and starting party")
 
 	outCh := make(chan tss.Message, len(s.LocalTssData.PartyIds))
 	endCh := make(chan *common.SignatureData, len(s.LocalTssData.PartyIds))
 
-	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA)
+	ecdsaMetaData, err := rosenTss.GetMetaData(models.ECDSA, s.SignMessage.KeyId)
 	if err != nil {
 		s.Logger.Errorf("there was an error in getting metadata: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
 	err = s.StartParty(&s.LocalTssData, ecdsaMetaData.Threshold, s.SignMessage, outCh, endCh)
 	if err != nil {
 		s.Logger.Errorf("there was an error in starting party: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
 	s.Logger.Debugf("party info: %v ", s.LocalTssData.Party)
 	go func() {
-		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh)
+		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh, done)
+		select {
+		case <-done:
+			// the action is already stopped (cancelled or timed out)
+			return
+		default:
+		}
 		if err != nil {
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		}
 		if !result {
 			err = fmt.Errorf("close channel")
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		} else {
 			s.Logger.Infof("end party successfully")
-			statusCh <- true
+			select {
+			case statusCh <- true:
+			case <-done:
+			}
 			return
 		}
 	}()
+	return nil
 }
 
 //	- reads new gossip messages from channel and handle it by calling related function in a go routine.
 func (s *operationECDSASign) StartAction(rosenTss _interface.RosenTss, messageCh chan models.GossipMessage, errorCh chan error) error {
 
-	partyStarted := false
 	statusCh := make(chan bool)
+	// done is closed when the action returns, so goroutines of the party stop instead of blocking
+	done := make(chan struct{})
+	defer close(done)
+
+	// the party is created before any message is handled, so goroutines of the messages only read it
+	err := s.CreateParty(rosenTss, statusCh, errorCh, done)
+	if err != nil {
+		return err
+	}
+	s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+	rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 
 	for {
 		select {
 		case err := <-errorCh:
 			if err.Error() == "close channel" {
-				close(messageCh)
 				return nil
 			}
 			return err
 		case msg, ok := <-messageCh:
 			if !ok {
-				if s.LocalTssData.Party != nil {
-					s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-				}
+				s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
 				return fmt.Errorf("communication channel is closed")
 			}
 			s.Logger.Infof("received new message from {%s} on communication channel", msg.SenderId)
@@ -138,44 +164,43 @@ func (s *operationECDSASign) StartAction(rosenTss _interface.RosenTss, messageCh
 				return err
 			}
 			go func() {
-				for {
-					if s.LocalTssData.Party == nil {
-						time.Sleep(time.Duration(rosenTss.GetConfig().WaitInPartyMessageHandling) * time.Millisecond)
-					} else {
-						break
-					}
+				// messages are not routed into the party after the action is stopped
+				select {
+				case <-done:
+					return
+				default:
 				}
 				s.Logger.Debugf("party info: %+v", s.LocalTssData.Party)
-				err = s.PartyUpdate(partyMsg)
+				err := s.PartyUpdate(partyMsg)
 				if err != nil {
 					s.Logger.Errorf("there was an error in handling party message: %+v", err)
-					errorCh <- err
+					select {
+					case errorCh <- err:
+					case <-done:
+					}
 				}
 				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+				rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 				return
 			}()
 		case end := <-statusCh:
 			if end {
 				return nil
 			}
-		default:
-			if s.LocalTssData.Party == nil && !partyStarted {
-				partyStarted = true
-				s.CreateParty(rosenTss, statusCh, errorCh)
-				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-			}
 		}
 	}
 }
 
 //	- create ecdsa sign operation
 func NewSignECDSAOperation(signMessage models.SignMessage) _interface.SignOperation {
-	logging = logger.NewSugar("ecdsa-sign")
+	loggingOnce.Do(func() {
+		logging = logger.NewSugar("ecdsa-sign")
+	})
 	return &operationECDSASign{
 		StructSign: sign.StructSign{
 			SignMessage: signMessage,
 			Logger:      logging,
-			Handler:     &ecdsaHandler,
+			Handler:     keyHandler(signMessage.KeyId),
 		},
 	}
 }
@@ -203,7 +228,8 @@ func (h *handler) StartParty(
 			}
 		}
 
-		il, extendedChildPk, err := derivingPubkeyFromPath(h.savedData.ECDSAPub, []byte(signMsg.ChainCode), signMsg.DerivationPath, tss.S256())
+		savedData := h.data()
+		il, extendedChildPk, err := derivingPubkeyFromPath(savedData.ECDSAPub, []byte(signMsg.ChainCode), signMsg.DerivationPath, tss.S256())
 
 		if err != nil {
 			return err
@@ -211,8 +237,8 @@ func (h *handler) StartParty(
 
 		keyDerivationDelta := il
 
-		// deep copy h.savedData to keys
-		origJSON, err1 := json.Marshal(h.savedData)
+		// deep copy savedData to keys
+		origJSON, err1 := json.Marshal(savedData)
 		if err1 != nil {
 			return err1
 		}
@@ -240,12 +266,14 @@ func (h *handler) StartParty(
 	return nil
 }
 
-//	- loads keygen data from file for signing
+//	- loads keygen data of the key from file for signing
 //	- creates tss party ID with p2pID
 func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
-	_, err1 := rosenTss.GetMetaData(models.ECDSA)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err1 := rosenTss.GetMetaData(models.ECDSA, h.keyId)
 	if h.savedData.ShareID == nil || (err1 != nil && err1.Error() == models.ECDSANoMetaDataFoundError) {
-		data, pID, err := rosenTss.GetStorage().LoadECDSAKeygen(rosenTss.GetPeerHome(), rosenTss.GetP2pId())
+		data, pID, err := rosenTss.GetStorage().LoadECDSAKeygen(rosenTss.GetPeerHome(), rosenTss.GetP2pId(), h.keyId)
 		if err != nil {
 			logging.Error(err)
 			return nil, err
@@ -256,7 +284,7 @@ func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
 		}
 		h.savedData = data.KeygenData
 		h.pID = pID
-		err = rosenTss.SetMetaData(data.MetaData, models.ECDSA)
+		err = rosenTss.SetMetaData(data.MetaData, models.ECDSA, h.keyId)
 		if err != nil {
 			return nil, err
 		}
@@ -264,9 +292,54 @@ func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
 	return h.pID, nil
 }
 
+//	- returns the handler of the key, loaded keygen data is kept per key
+func keyHandler(keyId string) *handler {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	h, ok := ecdsaHandlers[keyId]
+	if !ok {
+		h = &handler{keyId: keyId}
+		ecdsaHandlers[keyId] = h
+	}
+	return h
+}
+
+//	- drops the loaded keygen data of the key, so it is reloaded from storage on next sign
+func ResetData(keyId string) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	delete(ecdsaHandlers, keyId)
+}
+
+//	- returns the loaded keygen data, it is shared between sign operations
+func (h *handler) data() ecdsaKeygen.LocalPartySaveData {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.savedData
+}
+
+//	- verifies the signature against the public key derived for chain code and derivation path of the sign message
+//	- returns the hex of the derived public key
+func (h *handler) VerifySignature(signMsg models.SignMessage, signatureData *common.SignatureData) (string, error) {
+	_, extendedChildPk, err := derivingPubkeyFromPath(h.data().ECDSAPub, []byte(signMsg.ChainCode), signMsg.DerivationPath, tss.S256())
+	if err != nil {
+		return "", err
+	}
+	pk := extendedChildPk.PublicKey
+	pubKey := hex.EncodeToString(utils.GetPKFromECDSAPub(pk.X, pk.Y))
+
+	r := new(big.Int).SetBytes(signatureData.R)
+	s := new(big.Int).SetBytes(signatureData.S)
+	if !ecdsa.Verify(&pk, signatureData.M, r, s) {
+		return pubKey, fmt.Errorf("%s for pubKey %s", models.SignatureVerificationError, pubKey)
+	}
+	return pubKey, nil
+}
+
 //	- returns key_list and shared_ID of peer stored in the struct
 func (h *handler) GetData() ([]*big.Int, *big.Int) {
-	return h.savedData.Ks, h.savedData.ShareID
+	savedData := h.data()
+	return savedData.Ks, savedData.ShareID
 }
 
 // - derive on master pubKey according to bip32 (tss-lib modified version)
@@ -291,3 +364,18 @@ func derivingPubkeyFromPath(masterPub *crypto.ECPoint, chainCode []byte, path []
 
 	return ckd.DeriveChildKeyFromHierarchy(path, extendedParentPk, ec.Params().N, ec)
 }
+
+//	- derives the child public key of the master pubKey for chain code and derivation path
+//	- returns compressed and uncompressed child public key and the serialized extended public key
+func DerivePubKey(masterPub *crypto.ECPoint, chainCode string, path []uint32) (models.PubKeyData, error) {
+	_, extendedChildPk, err := derivingPubkeyFromPath(masterPub, []byte(chainCode), path, tss.S256())
+	if err != nil {
+		return models.PubKeyData{}, err
+	}
+	pkX, pkY := extendedChildPk.X, extendedChildPk.Y
+	return models.PubKeyData{
+		PubKey:             hex.EncodeToString(utils.GetPKFromECDSAPub(pkX, pkY)),
+		UncompressedPubKey: hex.EncodeToString(utils.GetUncompressedPKFromECDSAPub(pkX, pkY)),
+		ExtendedPubKey:     extendedChildPk.String(),
+	}, nil
+}
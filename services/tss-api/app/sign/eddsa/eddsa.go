@@ -1,20 +1,33 @@
 package eddsa
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/bnb-chain/tss-lib/v2/common"
+	"github.com/bnb-chain/tss-lib/v2/crypto"
+	"github.com/bnb-chain/tss-lib/v2/crypto/ckd"
 	eddsaKeygen "github.com/bnb-chain/tss-lib/v2/eddsa/keygen"
 	eddsaSigning "github.com/bnb-chain/tss-lib/v2/eddsa/signing"
 	"github.com/bnb-chain/tss-lib/v2/tss"
+	"github.com/btcsuite/btcutil/base58"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/blake2b"
+	"math"
 	"math/big"
 	"rosen-bridge/tss-api/app/interface"
 	"rosen-bridge/tss-api/app/sign"
 	"rosen-bridge/tss-api/logger"
 	"rosen-bridge/tss-api/models"
 	"rosen-bridge/tss-api/utils"
-	"time"
+	"sync"
 )
 
 type operationEDDSASign struct {
@@ -22,12 +35,20 @@ type operationEDDSASign struct {
 }
 
 type handler struct {
+	lock      sync.RWMutex
+	keyId     string
 	savedData eddsaKeygen.LocalPartySaveData
 	pID       *tss.PartyID
 }
 
 var logging *zap.SugaredLogger
-var eddsaHandler handler
+var loggingOnce sync.Once
+var handlersLock sync.Mutex
+var eddsaHandlers = make(map[string]*handler)
+
+// version of the serialized extended public key, the derivation is not bip32-ed25519 or slip-10, so it has its own
+// version and the serialized key starts with "edpub" instead of "xpub"
+var extendedPubKeyVersion = []byte{0xb1, 0x47, 0xd5, 0x79}
 
 //	- Initializes the eddsa sign partyId and peers
 func (s *operationEDDSASign) Init(rosenTss _interface.RosenTss, peers []models.Peer) error {
@@ -60,66 +81,87 @@ func (s *operationEDDSASign) Init(rosenTss _interface.RosenTss, peers []models.P
 //	- creates end and out channel for party,
 //	- calls StartParty function of protocol
 //	- handles end channel and out channel in a go routine
-func (s *operationEDDSASign) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error) {
+func (s *operationEDDSASign) CreateParty(rosenTss _interface.RosenTss, statusCh chan bool, errorCh chan error, done chan struct{}) error {
 	s.Logger.Info("creating and starting party")
 
 	outCh := make(chan tss.Message, len(s.LocalTssData.PartyIds))
 	endCh := make(chan *common.SignatureData, len(s.LocalTssData.PartyIds))
 
-	eddsaMetaData, err := rosenTss.GetMetaData(models.EDDSA)
+	eddsaMetaData, err := rosenTss.GetMetaData(models.EDDSA, s.SignMessage.KeyId)
 	if err != nil {
 		s.Logger.Errorf("there was an error in getting metadata: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
 	err = s.StartParty(&s.LocalTssData, eddsaMetaData.Threshold, s.SignMessage, outCh, endCh)
 	if err != nil {
 		s.Logger.Errorf("there was an error in starting party: %+v", err)
-		errorCh <- err
-		return
+		return err
 	}
 
 	s.Logger.Debugf("party info: %v ", s.LocalTssData.Party)
 	go func() {
-		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh)
+		result, err := s.GossipMessageHandler(rosenTss, outCh, endCh, done)
+		select {
+		case <-done:
+			// the action is already stopped (cancelled or timed out)
+			return
+		default:
+		}
 		if err != nil {
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		}
 		if !result {
 			err = fmt.Errorf("close channel")
 			s.Logger.Error(err)
-			errorCh <- err
+			select {
+			case errorCh <- err:
+			case <-done:
+			}
 			return
 		} else {
 			s.Logger.Infof("end party successfully")
-			statusCh <- true
+			select {
+			case statusCh <- true:
+			case <-done:
+			}
 			return
 		}
 	}()
+	return nil
 }
 
 //	- reads new gossip messages from channel and handle it by calling related function in a go routine.
 func (s *operationEDDSASign) StartAction(rosenTss _interface.RosenTss, messageCh chan models.GossipMessage, errorCh chan error) error {
 
-	partyStarted := false
 	statusCh := make(chan bool)
+	// done is closed when the action returns, so goroutines of the party stop instead of blocking
+	done := make(chan struct{})
+	defer close(done)
+
+	// the party is created before any message is handled, so goroutines of the messages only read it
+	err := s.CreateParty(rosenTss, statusCh, errorCh, done)
+	if err != nil {
+		return err
+	}
+	s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+	rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 
 	for {
 		select {
 		case err := <-errorCh:
 			if err.Error() == "close channel" {
-				close(messageCh)
 				return nil
 			}
 			return err
 		case msg, ok := <-messageCh:
 			if !ok {
-				if s.LocalTssData.Party != nil {
-					s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-				}
+				s.Logger.Infof("party was waiting for: %+v", s.LocalTssData.Party.WaitingFor())
 				return fmt.Errorf("communication channel is closed")
 			}
 			s.Logger.Infof("received new message from {%s} on communication channel", msg.SenderId)
@@ -133,44 +175,43 @@ func (s *operationEDDSASign) StartAction(rosenTss _interface.RosenTss, messageCh
 				return err
 			}
 			go func() {
-				for {
-					if s.LocalTssData.Party == nil {
-						time.Sleep(time.Duration(rosenTss.GetConfig().WaitInPartyMessageHandling) * time.Millisecond)
-					} else {
-						break
-					}
+				// messages are not routed into the party after the action is stopped
+				select {
+				case <-done:
+					return
+				default:
 				}
 				s.Logger.Debugf("party info: %+v", s.LocalTssData.Party)
-				err = s.PartyUpdate(partyMsg)
+				err := s.PartyUpdate(partyMsg)
 				if err != nil {
 					s.Logger.Errorf("there was an error in handling party message: %+v", err)
-					errorCh <- err
+					select {
+					case errorCh <- err:
+					case <-done:
+					}
 				}
 				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
+				rosenTss.GetRegistry().Progress(s.MessageId(), s.LocalTssData.Party)
 				return
 			}()
 		case end := <-statusCh:
 			if end {
 				return nil
 			}
-		default:
-			if s.LocalTssData.Party == nil && !partyStarted {
-				partyStarted = true
-				s.CreateParty(rosenTss, statusCh, errorCh)
-				s.Logger.Infof("party is waiting for: %+v", s.LocalTssData.Party.WaitingFor())
-			}
 		}
 	}
 }
 
 //	- create eddsa sign operation
 func NewSignEDDSAOperation(signMessage models.SignMessage) _interface.SignOperation {
-	logging = logger.NewSugar("eddsa-sign")
+	loggingOnce.Do(func() {
+		logging = logger.NewSugar("eddsa-sign")
+	})
 	return &operationEDDSASign{
 		StructSign: sign.StructSign{
 			SignMessage: signMessage,
 			Logger:      logging,
-			Handler:     &eddsaHandler,
+			Handler:     keyHandler(signMessage.KeyId),
 		},
 	}
 }
@@ -197,10 +238,24 @@ func (h *handler) StartParty(
 				localPartyId = peer
 			}
 		}
+		savedData := h.data()
+		key := savedData
+		if len(signMsg.DerivationPath) > 0 {
+			il, extendedChildPk, err := derivingPubkeyFromPath(savedData.EDDSAPub, []byte(signMsg.ChainCode), signMsg.DerivationPath, tss.Edwards())
+			if err != nil {
+				return err
+			}
+
+			key, err = updatePublicKeyAndAdjustShare(il, savedData, &extendedChildPk.PublicKey, tss.Edwards())
+			if err != nil {
+				return err
+			}
+		}
+
 		msgBytes, _ := utils.HexDecoder(signMsg.Message)
 		signDataBigInt := new(big.Int).SetBytes(msgBytes)
 		localTssData.Params = tss.NewParameters(tss.Edwards(), ctx, localPartyId, len(localTssData.PartyIds), threshold)
-		localTssData.Party = eddsaSigning.NewLocalParty(signDataBigInt, localTssData.Params, h.savedData, outCh, endCh, len(msgBytes))
+		localTssData.Party = eddsaSigning.NewLocalParty(signDataBigInt, localTssData.Params, key, outCh, endCh, len(msgBytes))
 
 		if err := localTssData.Party.Start(); err != nil {
 			return err
@@ -210,12 +265,14 @@ func (h *handler) StartParty(
 	return nil
 }
 
-//	- loads keygen data from file for signing
+//	- loads keygen data of the key from file for signing
 //	- creates tss party ID with p2pID
 func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
-	_, err1 := rosenTss.GetMetaData(models.EDDSA)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err1 := rosenTss.GetMetaData(models.EDDSA, h.keyId)
 	if h.savedData.ShareID == nil || (err1 != nil && err1.Error() == models.EDDSANoMetaDataFoundError) {
-		data, pID, err := rosenTss.GetStorage().LoadEDDSAKeygen(rosenTss.GetPeerHome(), rosenTss.GetP2pId())
+		data, pID, err := rosenTss.GetStorage().LoadEDDSAKeygen(rosenTss.GetPeerHome(), rosenTss.GetP2pId(), h.keyId)
 		if err != nil {
 			logging.Error(err)
 			return nil, err
@@ -226,7 +283,7 @@ func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
 		}
 		h.savedData = data.KeygenData
 		h.pID = pID
-		err = rosenTss.SetMetaData(data.MetaData, models.EDDSA)
+		err = rosenTss.SetMetaData(data.MetaData, models.EDDSA, h.keyId)
 		if err != nil {
 			return nil, err
 		}
@@ -234,7 +291,198 @@ func (h *handler) LoadData(rosenTss _interface.RosenTss) (*tss.PartyID, error) {
 	return h.pID, nil
 }
 
+//	- returns the handler of the key, loaded keygen data is kept per key
+func keyHandler(keyId string) *handler {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	h, ok := eddsaHandlers[keyId]
+	if !ok {
+		h = &handler{keyId: keyId}
+		eddsaHandlers[keyId] = h
+	}
+	return h
+}
+
+//	- drops the loaded keygen data of the key, so it is reloaded from storage on next sign
+func ResetData(keyId string) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	delete(eddsaHandlers, keyId)
+}
+
+//	- returns the loaded keygen data, it is shared between sign operations
+func (h *handler) data() eddsaKeygen.LocalPartySaveData {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.savedData
+}
+
+//	- verifies the signature with ed25519 against the public key derived for chain code and derivation path of the sign message
+//	- returns the hex of the (derived) public key
+func (h *handler) VerifySignature(signMsg models.SignMessage, signatureData *common.SignatureData) (string, error) {
+	savedData := h.data()
+	pk := savedData.EDDSAPub.ToECDSAPubKey()
+	if len(signMsg.DerivationPath) > 0 {
+		_, extendedChildPk, err := derivingPubkeyFromPath(savedData.EDDSAPub, []byte(signMsg.ChainCode), signMsg.DerivationPath, tss.Edwards())
+		if err != nil {
+			return "", err
+		}
+		pk = &extendedChildPk.PublicKey
+	}
+	pkBytes := utils.GetPKFromEDDSAPub(pk.X, pk.Y)
+	pubKey := hex.EncodeToString(pkBytes)
+
+	if len(signatureData.Signature) != ed25519.SignatureSize || !ed25519.Verify(pkBytes, signatureData.M, signatureData.Signature) {
+		return pubKey, fmt.Errorf("%s for pubKey %s", models.SignatureVerificationError, pubKey)
+	}
+	return pubKey, nil
+}
+
 //	- returns key_list and shared_ID of peer stored in the struct
 func (h *handler) GetData() ([]*big.Int, *big.Int) {
-	return h.savedData.Ks, h.savedData.ShareID
+	savedData := h.data()
+	return savedData.Ks, savedData.ShareID
+}
+
+// - derive on master pubKey according to bip32 non-hardened derivation, adapted for the edwards curve
+// - the edwards encoding of the parent key is used in hmac and il is reduced modulo the curve order
+// - return new keyDerivationDelta and extendedChildPk
+func derivingPubkeyFromPath(masterPub *crypto.ECPoint, chainCode []byte, path []uint32, ec elliptic.Curve) (*big.Int, *ckd.ExtendedKey, error) {
+	// build eddsa key pair
+	pk := ecdsa.PublicKey{
+		Curve: ec,
+		X:     masterPub.X(),
+		Y:     masterPub.Y(),
+	}
+
+	extendedKey := &ckd.ExtendedKey{
+		PublicKey:  pk,
+		Depth:      0,
+		ChildIndex: 0,
+		ChainCode:  chainCode[:],
+		ParentFP:   []byte{0x00, 0x00, 0x00, 0x00},
+		Version:    extendedPubKeyVersion,
+	}
+
+	modN := common.ModInt(ec.Params().N)
+	keyDerivationDelta := big.NewInt(0)
+	for _, index := range path {
+		il, childKey, err := deriveChildKey(index, extendedKey, ec)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyDerivationDelta = modN.Add(keyDerivationDelta, il)
+		extendedKey = childKey
+	}
+	return keyDerivationDelta, extendedKey, nil
+}
+
+// - derive a non-hardened child of an edwards extended public key
+// - return il and the child extended public key
+func deriveChildKey(index uint32, pk *ckd.ExtendedKey, ec elliptic.Curve) (*big.Int, *ckd.ExtendedKey, error) {
+	if index >= ckd.HardenedKeyStart {
+		return nil, nil, fmt.Errorf("the index must be non-hardened")
+	}
+	if pk.Depth == math.MaxUint8 {
+		return nil, nil, fmt.Errorf("cannot derive key beyond max depth")
+	}
+
+	cryptoPk, err := crypto.NewECPoint(ec, pk.X, pk.Y)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkPublicKeyBytes := utils.GetPKFromEDDSAPub(pk.X, pk.Y)
+	data := make([]byte, len(pkPublicKeyBytes)+4)
+	copy(data, pkPublicKeyBytes)
+	binary.BigEndian.PutUint32(data[len(pkPublicKeyBytes):], index)
+
+	hmac512 := hmac.New(sha512.New, pk.ChainCode)
+	hmac512.Write(data)
+	ilr := hmac512.Sum(nil)
+	ilNum := new(big.Int).Mod(new(big.Int).SetBytes(ilr[:32]), ec.Params().N)
+	if ilNum.Sign() == 0 {
+		return nil, nil, fmt.Errorf("invalid derived key")
+	}
+
+	childCryptoPk, err := cryptoPk.Add(crypto.ScalarBaseMult(ec, ilNum))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parentFP := blake2b.Sum256(pkPublicKeyBytes)
+	childPk := &ckd.ExtendedKey{
+		PublicKey:  *childCryptoPk.ToECDSAPubKey(),
+		Depth:      pk.Depth + 1,
+		ChildIndex: index,
+		ChainCode:  ilr[32:],
+		ParentFP:   parentFP[:4],
+		Version:    pk.Version,
+	}
+	return ilNum, childPk, nil
+}
+
+// - shifts the local share, BigXj and public key of a copy of keygen data by keyDerivationDelta
+// - since lagrange coefficients sum to one, shifted shares are shares of the shifted secret
+func updatePublicKeyAndAdjustShare(
+	keyDerivationDelta *big.Int, savedData eddsaKeygen.LocalPartySaveData, extendedChildPk *ecdsa.PublicKey, ec elliptic.Curve,
+) (eddsaKeygen.LocalPartySaveData, error) {
+	// deep copy savedData to key
+	origJSON, err := json.Marshal(savedData)
+	if err != nil {
+		return eddsaKeygen.LocalPartySaveData{}, err
+	}
+	key := eddsaKeygen.LocalPartySaveData{}
+	if err = json.Unmarshal(origJSON, &key); err != nil {
+		return eddsaKeygen.LocalPartySaveData{}, err
+	}
+
+	key.Xi = common.ModInt(ec.Params().N).Add(key.Xi, keyDerivationDelta)
+	key.EDDSAPub, err = crypto.NewECPoint(ec, extendedChildPk.X, extendedChildPk.Y)
+	if err != nil {
+		return eddsaKeygen.LocalPartySaveData{}, err
+	}
+	gDelta := crypto.ScalarBaseMult(ec, keyDerivationDelta)
+	for j := range key.BigXj {
+		key.BigXj[j], err = key.BigXj[j].Add(gDelta)
+		if err != nil {
+			return eddsaKeygen.LocalPartySaveData{}, err
+		}
+	}
+	return key, nil
+}
+
+//	- derives the child public key of the master pubKey for chain code and derivation path
+//	- returns the child public key and the serialized extended public key
+func DerivePubKey(masterPub *crypto.ECPoint, chainCode string, path []uint32) (models.PubKeyData, error) {
+	_, extendedChildPk, err := derivingPubkeyFromPath(masterPub, []byte(chainCode), path, tss.Edwards())
+	if err != nil {
+		return models.PubKeyData{}, err
+	}
+	return models.PubKeyData{
+		PubKey:         hex.EncodeToString(utils.GetPKFromEDDSAPub(extendedChildPk.X, extendedChildPk.Y)),
+		ExtendedPubKey: serializeExtendedKey(extendedChildPk),
+	}, nil
+}
+
+//	- serializes the extended public key in bip32 layout with its own version, the key is 0x00 followed by the edwards
+//	encoding and the parent fingerprint is the first 4 bytes of blake2b-256 of the edwards encoding of the parent
+func serializeExtendedKey(k *ckd.ExtendedKey) string {
+	// version(4) || depth(1) || parentFP (4) || childIndex(4) || chaincode (32) || key(33) || checksum(4)
+	var childNumBytes [4]byte
+	binary.BigEndian.PutUint32(childNumBytes[:], k.ChildIndex)
+
+	serializedBytes := make([]byte, 0)
+	serializedBytes = append(serializedBytes, k.Version...)
+	serializedBytes = append(serializedBytes, k.Depth)
+	serializedBytes = append(serializedBytes, k.ParentFP...)
+	serializedBytes = append(serializedBytes, childNumBytes[:]...)
+	serializedBytes = append(serializedBytes, k.ChainCode...)
+	serializedBytes = append(serializedBytes, 0x00)
+	serializedBytes = append(serializedBytes, utils.GetPKFromEDDSAPub(k.X, k.Y)...)
+
+	first := sha256.Sum256(serializedBytes)
+	second := sha256.Sum256(first[:])
+	serializedBytes = append(serializedBytes, second[:4]...)
+	return base58.Encode(serializedBytes)
 }
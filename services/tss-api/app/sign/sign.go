@@ -22,6 +22,7 @@ type Handler interface {
 		outCh chan tss.Message,
 		endCh chan *common.SignatureData,
 	) error
+	VerifySignature(signMsg models.SignMessage, signatureData *common.SignatureData) (string, error)
 }
 
 type StructSign struct {
@@ -32,6 +33,18 @@ type StructSign struct {
 	Handler
 }
 
+//	- returns the messageId of sign messages, based on hash of the sign message
+func (s *StructSign) MessageId() string {
+	msgBytes, _ := utils.HexDecoder(s.SignMessage.Message)
+	messageBytes := blake2b.Sum256(msgBytes)
+	return utils.KeyMessageId(fmt.Sprintf("%s%s", s.SignMessage.Crypto, utils.HexEncoder(messageBytes[:])), s.SignMessage.KeyId)
+}
+
+//	- returns the keyId of the sign
+func (s *StructSign) GetKeyId() string {
+	return s.SignMessage.KeyId
+}
+
 //	- finds the index of peer in the key list.
 //	- creates a gossip message from payload.
 //	- sends the gossip message to Publish function.
@@ -50,7 +63,7 @@ func (s *StructSign) NewMessage(rosenTss _interface.RosenTss, payload models.Pay
 		SenderId:   payload.SenderId,
 		ReceiverId: receiver,
 	}
-	err := rosenTss.GetConnection().Publish(gossipMessage)
+	err := rosenTss.Publish(gossipMessage)
 	if err != nil {
 		return err
 	}
@@ -58,6 +71,7 @@ func (s *StructSign) NewMessage(rosenTss _interface.RosenTss, payload models.Pay
 }
 
 //	- handles party messages on out channel
+//	- records round of the message in progress of the operation
 //	- creates payload from party message
 //	- send it to NewMessage function
 func (s *StructSign) HandleOutMessage(rosenTss _interface.RosenTss, partyMsg tss.Message) error {
@@ -66,13 +80,11 @@ func (s *StructSign) HandleOutMessage(rosenTss _interface.RosenTss, partyMsg tss
 		s.Logger.Errorf("there was an error in parsing party message to the struct: %+v", err)
 		return err
 	}
+	rosenTss.GetRegistry().Round(s.MessageId(), partyMsg)
 
-	msgBytes, _ := utils.HexDecoder(s.SignMessage.Message)
-	messageBytes := blake2b.Sum256(msgBytes)
-	messageId := fmt.Sprintf("%s%s", s.SignMessage.Crypto, utils.HexEncoder(messageBytes[:]))
 	payload := models.Payload{
 		Message:   msgHex,
-		MessageId: messageId,
+		MessageId: s.MessageId(),
 		SenderId:  s.LocalTssData.PartyID.Id,
 	}
 
@@ -93,21 +105,39 @@ func (s *StructSign) HandleOutMessage(rosenTss _interface.RosenTss, partyMsg tss
 }
 
 //	- handles save data (signature) on end channel of party
+//	- verifies the signature against the (derived) public key
 //	- logs the data and send it to CallBack
 func (s *StructSign) HandleEndMessage(rosenTss _interface.RosenTss, signatureData *common.SignatureData) error {
 
+	pubKey, err := s.VerifySignature(s.SignMessage, signatureData)
+	if err != nil {
+		s.Logger.Errorf("signature of Message: {%s} is not valid: %+v", s.SignMessage.Message, err)
+		return err
+	}
+
 	signData := models.SignData{
 		Signature:         utils.HexEncoder(signatureData.Signature),
 		Message:           utils.HexEncoder(signatureData.M),
 		SignatureRecovery: utils.HexEncoder(signatureData.SignatureRecovery),
-		TrustKey:          rosenTss.GetTrustKey(),
+		PubKey:            pubKey,
+		KeyId:             s.SignMessage.KeyId,
+		DerivationPath:    s.SignMessage.DerivationPath,
+		TrustKey:          rosenTss.GetCallbackTrustKey(),
 		Status:            "success",
 	}
 
 	s.Logger.Infof("signing process for Message: {%s} and Crypto: {%s} finished.", s.SignMessage.Message, s.SignMessage.Crypto)
 	s.Logger.Debugf("signature: {%v}, Message: {%v}, SignatureRecovery: {%v}", signData.Signature, signData.Message, signData.SignatureRecovery)
 
-	err := rosenTss.GetConnection().CallBack(s.SignMessage.CallBackUrl, signData)
+	// the trust key is only sent to the callback, the result of the operation is readable through the status api
+	result := signData
+	result.TrustKey = ""
+	rosenTss.GetRegistry().SetResult(s.MessageId(), result)
+	// signatures of a batch are sent in the callback of the batch
+	if s.SignMessage.BatchId != "" {
+		return nil
+	}
+	err = rosenTss.CallBack(s.SignMessage.CallBackUrl, signData)
 	if err != nil {
 		return err
 	}
@@ -116,14 +146,19 @@ func (s *StructSign) HandleEndMessage(rosenTss _interface.RosenTss, signatureDat
 
 //	- handles all party messages on outCh and endCh
 //	- listens to channels and send the message to the right function
+//	- stops when done is closed, channels of the stopped party are drained in the background
 func (s *StructSign) GossipMessageHandler(
-	rosenTss _interface.RosenTss, outCh chan tss.Message, endCh chan *common.SignatureData,
+	rosenTss _interface.RosenTss, outCh chan tss.Message, endCh chan *common.SignatureData, done chan struct{},
 ) (bool, error) {
 	for {
 		select {
+		case <-done:
+			utils.DrainParty(outCh, endCh)
+			return false, nil
 		case partyMsg := <-outCh:
 			err := s.HandleOutMessage(rosenTss, partyMsg)
 			if err != nil {
+				utils.DrainParty(outCh, endCh)
 				return false, err
 			}
 		case save := <-endCh:
@@ -136,8 +171,16 @@ func (s *StructSign) GossipMessageHandler(
 	}
 }
 
+//	- checks the sender is a party of the operation
 //	- Updates party on received message destination.
 func (s *StructSign) PartyUpdate(partyMsg models.PartyMessage) error {
+	// the sender of the wire is replaced with the party of the operation
+	from, err := utils.PartySender(partyMsg.GetFrom, s.LocalTssData.PartyIds)
+	if err != nil {
+		return err
+	}
+	partyMsg.GetFrom = from
+
 	dest := partyMsg.GetTo
 	if dest == nil { // broadcast!
 		if s.LocalTssData.Party.PartyID().Index == partyMsg.GetFrom.Index {
@@ -152,7 +195,7 @@ func (s *StructSign) PartyUpdate(partyMsg models.PartyMessage) error {
 		s.Logger.Infof("updating party state with p2p message")
 	}
 
-	err := s.SignOperationHandler.SharedPartyUpdater(s.LocalTssData.Party, partyMsg)
+	err = s.SignOperationHandler.SharedPartyUpdater(s.LocalTssData.Party, partyMsg)
 	if err != nil {
 		return err
 	}
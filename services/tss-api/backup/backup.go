@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/app/keygen"
+	"rosen-bridge/tss-api/integrity"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	bundleVersion = 1
+)
+
+//	a key share of the bundle, data holds the keygen data as it is stored
+type Key struct {
+	Crypto   string          `json:"crypto"`
+	KeyId    string          `json:"keyId"`
+	PubKey   string          `json:"pubKey"`
+	ShareID  string          `json:"shareID"`
+	MetaData models.MetaData `json:"metaData"`
+	Data     json.RawMessage `json:"data"`
+}
+
+//	all key shares of a peer with the p2pId they were created for
+type Bundle struct {
+	P2pId     string    `json:"p2pId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Keys      []Key     `json:"keys"`
+}
+
+//	format of a bundle file, checksum is the sha256 of the encrypted content
+type bundleFile struct {
+	Version   int    `json:"version"`
+	Checksum  string `json:"checksum"`
+	Encrypted string `json:"encrypted"`
+}
+
+var logging *zap.SugaredLogger
+
+//	creates a bundle of all stored key shares of the peer encrypted with the passphrase
+func Export(store storage.Storage, peerHome string, p2pId string, passphrase string) ([]byte, Bundle, error) {
+	logging = logger.NewSugar("backup")
+	bundle := Bundle{
+		P2pId:     p2pId,
+		CreatedAt: time.Now().UTC(),
+		Keys:      make([]Key, 0),
+	}
+	for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+		keyIds, err := store.ListKeys(peerHome, protocol)
+		if err != nil {
+			return nil, Bundle{}, err
+		}
+		for _, keyId := range keyIds {
+			var data interface{}
+			key := Key{
+				Crypto: protocol,
+				KeyId:  keyId,
+			}
+			switch protocol {
+			case models.EDDSA:
+				tssConfig, _, err := store.LoadEDDSAKeygen(peerHome, p2pId, keyId)
+				if err != nil {
+					return nil, Bundle{}, err
+				}
+				pkX, pkY := tssConfig.KeygenData.EDDSAPub.X(), tssConfig.KeygenData.EDDSAPub.Y()
+				key.PubKey = utils.HexEncoder(utils.GetPKFromEDDSAPub(pkX, pkY))
+				key.ShareID = tssConfig.KeygenData.ShareID.String()
+				key.MetaData = tssConfig.MetaData
+				data = tssConfig
+			case models.ECDSA:
+				tssConfig, _, err := store.LoadECDSAKeygen(peerHome, p2pId, keyId)
+				if err != nil {
+					return nil, Bundle{}, err
+				}
+				pkX, pkY := tssConfig.KeygenData.ECDSAPub.X(), tssConfig.KeygenData.ECDSAPub.Y()
+				key.PubKey = utils.HexEncoder(utils.GetPKFromECDSAPub(pkX, pkY))
+				key.ShareID = tssConfig.KeygenData.ShareID.String()
+				key.MetaData = tssConfig.MetaData
+				data = tssConfig
+			}
+			bz, err := json.Marshal(data)
+			if err != nil {
+				return nil, Bundle{}, err
+			}
+			key.Data = bz
+			bundle.Keys = append(bundle.Keys, key)
+			logging.Infof("%s key %s added to the bundle", protocol, keyId)
+		}
+	}
+
+	bz, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, Bundle{}, err
+	}
+	encrypted, err := utils.Encrypt(bz, passphrase)
+	if err != nil {
+		return nil, Bundle{}, err
+	}
+	checksum := sha256.Sum256(encrypted)
+	bz, err = json.MarshalIndent(bundleFile{
+		Version:   bundleVersion,
+		Checksum:  utils.HexEncoder(checksum[:]),
+		Encrypted: utils.HexEncoder(encrypted),
+	}, "", "    ")
+	if err != nil {
+		return nil, Bundle{}, err
+	}
+	return bz, bundle, nil
+}
+
+//	decrypts the bundle and checks its checksum
+func Open(data []byte, passphrase string) (Bundle, error) {
+	var file bundleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Bundle{}, fmt.Errorf("invalid bundle file, err:{%v}", err)
+	}
+	if file.Version != bundleVersion {
+		return Bundle{}, fmt.Errorf(models.WrongBundleVersionError)
+	}
+	encrypted, err := utils.HexDecoder(file.Encrypted)
+	if err != nil {
+		return Bundle{}, fmt.Errorf(models.BundleChecksumError)
+	}
+	checksum := sha256.Sum256(encrypted)
+	if utils.HexEncoder(checksum[:]) != file.Checksum {
+		return Bundle{}, fmt.Errorf(models.BundleChecksumError)
+	}
+	bz, err := utils.Decrypt(encrypted, passphrase)
+	if err != nil {
+		return Bundle{}, fmt.Errorf(models.WrongBundlePassphraseError)
+	}
+	var bundle Bundle
+	if err = json.Unmarshal(bz, &bundle); err != nil {
+		return Bundle{}, fmt.Errorf("invalid bundle content, err:{%v}", err)
+	}
+	return bundle, nil
+}
+
+//	stores key shares of the bundle, all shares are checked before anything is written and are written at once.
+//	the bundle must be created for the p2pId of the peer unless ignoreP2pId is set
+func Import(store storage.Storage, peerHome string, p2pId string, data []byte, passphrase string, ignoreP2pId bool) (Bundle, error) {
+	logging = logger.NewSugar("backup")
+	bundle, err := Open(data, passphrase)
+	if err != nil {
+		return Bundle{}, err
+	}
+	if bundle.P2pId != p2pId {
+		if !ignoreP2pId {
+			return Bundle{}, fmt.Errorf("%s: %s != %s", models.P2pIdMismatchError, bundle.P2pId, p2pId)
+		}
+		logging.Warnf("bundle is created for p2pId %s, it is imported for %s", bundle.P2pId, p2pId)
+	}
+
+	stored := make(map[string]bool)
+	for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+		keyIds, err := store.ListKeys(peerHome, protocol)
+		if err != nil {
+			return Bundle{}, err
+		}
+		for _, keyId := range keyIds {
+			stored[protocol+"/"+keyId] = true
+		}
+	}
+	values := make([]storage.KeyData, len(bundle.Keys))
+	imported := make(map[string]bool)
+	for i, key := range bundle.Keys {
+		if _, err := utils.CheckKeyId(key.KeyId); err != nil || key.KeyId == "" {
+			return Bundle{}, fmt.Errorf("%s: %s", models.InvalidKeyIdError, key.KeyId)
+		}
+		if stored[key.Crypto+"/"+key.KeyId] {
+			return Bundle{}, fmt.Errorf("%s: %s key %s", models.KeygenFileExistError, key.Crypto, key.KeyId)
+		}
+		// a repeated key would overwrite the earlier one in the write
+		if imported[key.Crypto+"/"+key.KeyId] {
+			return Bundle{}, fmt.Errorf("%s: %s key %s", models.DuplicatedBundleKeyError, key.Crypto, key.KeyId)
+		}
+		imported[key.Crypto+"/"+key.KeyId] = true
+		data, err := checkKey(key, p2pId)
+		if err != nil {
+			return Bundle{}, fmt.Errorf("%s key %s: %v", key.Crypto, key.KeyId, err)
+		}
+		values[i] = storage.KeyData{Protocol: key.Crypto, KeyId: key.KeyId, Data: data}
+	}
+
+	// keys are written at once, so a failed write does not leave a partial import
+	err = store.WriteKeys(values, peerHome, keygen.KeygenFileName)
+	if err != nil {
+		return Bundle{}, err
+	}
+	return bundle, nil
+}
+
+//	parses the keygen data of the key and checks its integrity (the public share of the peer and the public key which
+//	is interpolated from public shares of all peers), public key, share id and meta data listed in the bundle must
+//	match the data, returns the keygen data to be stored
+func checkKey(key Key, p2pId string) (interface{}, error) {
+	switch key.Crypto {
+	case models.EDDSA:
+		tssConfig, _, err := storage.ParseEDDSAKeygen(key.Data, p2pId)
+		if err != nil {
+			return nil, err
+		}
+		if errors := integrity.CheckEDDSA(tssConfig); len(errors) > 0 {
+			return nil, fmt.Errorf("%s", strings.Join(errors, ", "))
+		}
+		data := tssConfig.KeygenData
+		pubKey := utils.HexEncoder(utils.GetPKFromEDDSAPub(data.EDDSAPub.X(), data.EDDSAPub.Y()))
+		if err = checkListed(key, pubKey, data.ShareID.String(), tssConfig.MetaData); err != nil {
+			return nil, err
+		}
+		return tssConfig, nil
+	case models.ECDSA:
+		tssConfig, _, err := storage.ParseECDSAKeygen(key.Data, p2pId)
+		if err != nil {
+			return nil, err
+		}
+		errors, warnings := integrity.CheckECDSA(tssConfig)
+		if len(errors) > 0 {
+			return nil, fmt.Errorf("%s", strings.Join(errors, ", "))
+		}
+		if len(warnings) > 0 {
+			logging.Warnf("ecdsa key %s is partially checked: %s", key.KeyId, strings.Join(warnings, ", "))
+		}
+		data := tssConfig.KeygenData
+		pubKey := utils.HexEncoder(utils.GetPKFromECDSAPub(data.ECDSAPub.X(), data.ECDSAPub.Y()))
+		if err = checkListed(key, pubKey, data.ShareID.String(), tssConfig.MetaData); err != nil {
+			return nil, err
+		}
+		return tssConfig, nil
+	default:
+		return nil, fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+}
+
+//	checks the public key, share id and meta data listed in the bundle are the ones of the keygen data
+func checkListed(key Key, pubKey string, shareID string, metaData models.MetaData) error {
+	if pubKey != key.PubKey || shareID != key.ShareID || metaData != key.MetaData {
+		return fmt.Errorf(models.ShareMismatchError)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/backup"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/network"
+	"rosen-bridge/tss-api/storage"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	exportCommand = "export"
+	importCommand = "import"
+)
+
+//	runs the export or import command, key shares are moved between hosts with a passphrase encrypted bundle
+func runBundleCommand(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	configFile := flags.String("configFile", "./conf/conf.env", "config file")
+	guardUrl := flags.String("guardUrl", "http://localhost:8080", "guard url (e.g. http://localhost:8080)")
+	getPeerIDPath := flags.String("getP2PIDPath", "/p2p/getPeerID", "getP2PIDPath for p2p (e.g. /p2p/getPeerID)")
+	p2pId := flags.String("p2pId", "", "p2pId of the peer, it is asked from the guard if it is not set")
+	bundleFile := flags.String("bundle", "", "path of the bundle file")
+	bundlePassphraseFile := flags.String(
+		"bundlePassphraseFile", "", "file of the bundle passphrase, TSS_BUNDLE_PASSPHRASE is used if it is not set",
+	)
+	passphraseStdin := flags.Bool(
+		"passphraseStdin", false, "read passphrase of key shares from stdin, if TSS_PASSPHRASE_FILE and TSS_PASSPHRASE are not set",
+	)
+	ignoreP2pId := flags.Bool("ignoreP2pId", false, "import a bundle created for another p2pId")
+	_ = flags.Parse(args)
+
+	// initiating and reading configs
+	config, err := utils.InitConfig(*configFile)
+	if err != nil {
+		panic(err)
+	}
+
+	absLogAddress, err := utils.SetupDir(config.LogAddress)
+	if err != nil {
+		panic(err)
+	}
+
+	logFile := fmt.Sprintf("%s/%s", absLogAddress, "tss.log")
+	err = logger.Init(logFile, config, false)
+	if err != nil {
+		panic(err)
+	}
+
+	logging := logger.NewSugar(command)
+
+	defer func() {
+		err = logger.Sync()
+		if err != nil {
+			logging.Error(err)
+		}
+	}()
+
+	if *bundleFile == "" {
+		logging.Fatal("the bundle flag is not set")
+	}
+
+	// reading passphrases of key shares and the bundle
+	passphrase, err := utils.ReadPassphrase(config.PassphraseFile, *passphraseStdin)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	bundlePassphrase, err := utils.ReadBundlePassphrase(*bundlePassphraseFile)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	localStorage, err := storage.NewStorage(config, passphrase)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	defer func() {
+		err = localStorage.Close()
+		if err != nil {
+			logging.Error(err)
+		}
+	}()
+
+	peerHome, err := utils.SetupDir(config.HomeAddress)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	err = localStorage.MigrateKeys(peerHome)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	// the party ID of each share is created from the p2pId of the peer
+	if *p2pId == "" {
+		*p2pId, err = network.InitConnection("", "", *guardUrl, *getPeerIDPath, "").GetPeerId()
+		if err != nil {
+			logging.Fatalf("unable to get p2pId from the guard, set the p2pId flag, err: %+v", err)
+		}
+	}
+
+	switch command {
+	case exportCommand:
+		exportBundle(logging, localStorage, peerHome, *p2pId, *bundleFile, bundlePassphrase)
+	case importCommand:
+		importBundle(logging, localStorage, peerHome, *p2pId, *bundleFile, bundlePassphrase, *ignoreP2pId)
+	}
+}
+
+//	writes the bundle of all key shares to a new file
+func exportBundle(logging *zap.SugaredLogger, store storage.Storage, peerHome string, p2pId string, path string, passphrase string) {
+	data, bundle, err := backup.Export(store, peerHome, p2pId, passphrase)
+	if err != nil {
+		checkPassphraseError(logging, err)
+		logging.Fatal(err)
+	}
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	_, err = fd.Write(data)
+	closeErr := fd.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logging.Fatal(err)
+	}
+	logging.Infof("%d keys of p2pId %s exported to %s", len(bundle.Keys), p2pId, path)
+}
+
+//	stores key shares of the bundle file
+func importBundle(
+	logging *zap.SugaredLogger, store storage.Storage, peerHome string, p2pId string, path string, passphrase string,
+	ignoreP2pId bool,
+) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	bundle, err := backup.Import(store, peerHome, p2pId, data, passphrase, ignoreP2pId)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	for _, key := range bundle.Keys {
+		logging.Infof("%s key %s imported, pubKey: %s, shareID: %s", key.Crypto, key.KeyId, key.PubKey, key.ShareID)
+	}
+	logging.Infof("%d keys of p2pId %s imported from %s", len(bundle.Keys), bundle.P2pId, path)
+}
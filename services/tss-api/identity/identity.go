@@ -0,0 +1,351 @@
+package identity
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+const (
+	identityVersion = 1
+	encryptionInfo  = "rosen-tss p2p message"
+	defaultWindow   = 300
+)
+
+type Identity interface {
+	SigningKey() string
+	EncryptionKey() string
+	Authenticated() bool
+	Sign(message models.GossipMessage) (models.GossipMessage, error)
+	Verify(message models.GossipMessage) error
+	Encrypt(message models.GossipMessage) (models.GossipMessage, error)
+	Decrypt(message models.GossipMessage) (models.GossipMessage, error)
+}
+
+//	stored format of the identity, signingKey holds the hex of the ed25519 seed and encryptionKey the hex of
+//	the x25519 private key
+type identityData struct {
+	Version       int    `json:"version"`
+	SigningKey    string `json:"signingKey"`
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+}
+
+type peer struct {
+	signingKey    ed25519.PublicKey
+	encryptionKey []byte
+}
+
+type identity struct {
+	signingKey    ed25519.PrivateKey
+	encryptionKey []byte
+	peers         map[string]peer
+	window        time.Duration
+	lock          sync.Mutex
+	received      map[string]time.Time
+	cleanedAt     time.Time
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of the identity of the peer, the identity key is created on first start and kept in the storage,
+//	identity keys of other peers are loaded from the peers file of config. the peers file is required unless
+//	unauthenticated p2p is allowed in config, messages are not authenticated without it
+func NewIdentity(store storage.Storage, peerHome string, config models.Config) (Identity, error) {
+	logging = logger.NewSugar("identity")
+	id, err := loadIdentity(store, peerHome)
+	if err != nil {
+		return nil, err
+	}
+	window := config.MessageSignatureWindow
+	if window <= 0 {
+		window = defaultWindow
+	}
+	id.window = time.Second * time.Duration(window)
+	id.received = make(map[string]time.Time)
+	if config.PeersFile == "" {
+		if !config.P2pUnauthenticated {
+			return nil, fmt.Errorf(models.PeersFileRequiredError)
+		}
+		logging.Warn("no peers file is set, p2p messages are not authenticated")
+		return id, nil
+	}
+	id.peers, err = loadPeers(config.PeersFile)
+	if err != nil {
+		return nil, err
+	}
+	logging.Infof("identity keys of %d peers loaded", len(id.peers))
+	return id, nil
+}
+
+//	loads the identity keys of the peer from the storage, missing keys are created and stored
+func loadIdentity(store storage.Storage, peerHome string) (*identity, error) {
+	bz, err := store.LoadIdentity(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	data := identityData{Version: identityVersion}
+	if bz != nil {
+		if err := json.Unmarshal(bz, &data); err != nil {
+			return nil, err
+		}
+	}
+
+	id := &identity{}
+	changed := false
+	if data.SigningKey == "" {
+		_, id.signingKey, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		data.SigningKey = hex.EncodeToString(id.signingKey.Seed())
+		changed = true
+	} else {
+		seed, err := hex.DecodeString(data.SigningKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid identity key")
+		}
+		id.signingKey = ed25519.NewKeyFromSeed(seed)
+	}
+	if data.EncryptionKey == "" {
+		id.encryptionKey = make([]byte, curve25519.ScalarSize)
+		if _, err := io.ReadFull(rand.Reader, id.encryptionKey); err != nil {
+			return nil, err
+		}
+		data.EncryptionKey = hex.EncodeToString(id.encryptionKey)
+		changed = true
+	} else {
+		id.encryptionKey, err = hex.DecodeString(data.EncryptionKey)
+		if err != nil || len(id.encryptionKey) != curve25519.ScalarSize {
+			return nil, fmt.Errorf("invalid identity encryption key")
+		}
+	}
+
+	if changed {
+		bz, err = json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		if err := store.SaveIdentity(peerHome, bz); err != nil {
+			return nil, err
+		}
+		logging.Info("new identity keys created")
+	}
+	return id, nil
+}
+
+//	reads identity keys of peers, the file holds a list of identities as returned by GET /identity of each peer
+func loadPeers(peersFile string) (map[string]peer, error) {
+	bz, err := ioutil.ReadFile(peersFile)
+	if err != nil {
+		return nil, err
+	}
+	var identities []models.PeerIdentity
+	if err := json.Unmarshal(bz, &identities); err != nil {
+		return nil, fmt.Errorf("unable to parse peers file %s, err:{%v}", peersFile, err)
+	}
+	peers := make(map[string]peer)
+	for _, item := range identities {
+		signingKey, err := hex.DecodeString(item.SigningKey)
+		if err != nil || len(signingKey) != ed25519.PublicKeySize || item.P2pId == "" {
+			return nil, fmt.Errorf("invalid identity of peer %s in peers file", item.P2pId)
+		}
+		encryptionKey, err := hex.DecodeString(item.EncryptionKey)
+		if err != nil || len(encryptionKey) != curve25519.PointSize {
+			return nil, fmt.Errorf("invalid encryption key of peer %s in peers file", item.P2pId)
+		}
+		peers[item.P2pId] = peer{
+			signingKey:    signingKey,
+			encryptionKey: encryptionKey,
+		}
+	}
+	return peers, nil
+}
+
+//	returns hex of the public identity key of the peer
+func (i *identity) SigningKey() string {
+	return hex.EncodeToString(i.signingKey.Public().(ed25519.PublicKey))
+}
+
+//	returns hex of the public encryption key of the peer
+func (i *identity) EncryptionKey() string {
+	key, err := curve25519.X25519(i.encryptionKey, curve25519.Basepoint)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(key)
+}
+
+//	returns true if inbound messages are verified against identity keys of peers
+func (i *identity) Authenticated() bool {
+	return i.peers != nil
+}
+
+//	signs the message and its timestamp with the identity key
+func (i *identity) Sign(message models.GossipMessage) (models.GossipMessage, error) {
+	message.Timestamp = time.Now().Unix()
+	bz, err := signedBytes(message)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	message.Signature = hex.EncodeToString(ed25519.Sign(i.signingKey, bz))
+	return message, nil
+}
+
+//	verifies signature of the message with the registered identity key of its sender, the message must be signed
+//	within the time window and is accepted once, so captured messages can not be replayed. without identity keys of
+//	peers only unsigned messages and messages out of the window are rejected
+func (i *identity) Verify(message models.GossipMessage) error {
+	signature, err := hex.DecodeString(message.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf(models.MessageSignatureError)
+	}
+	now := time.Now()
+	diff := now.Sub(time.Unix(message.Timestamp, 0))
+	if diff > i.window || diff < -i.window {
+		return fmt.Errorf(models.MessageTimestampError)
+	}
+	if i.peers == nil {
+		return nil
+	}
+	sender, ok := i.peers[message.SenderId]
+	if !ok {
+		return fmt.Errorf(models.UnknownPeerError)
+	}
+	bz, err := signedBytes(message)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(sender.signingKey, bz, signature) {
+		return fmt.Errorf(models.MessageSignatureError)
+	}
+	if !i.receive(message.Signature, now) {
+		return fmt.Errorf(models.MessageReplayedError)
+	}
+	return nil
+}
+
+//	records the signature of a verified message, returns false if it is received in the time window, expired
+//	signatures are removed
+func (i *identity) receive(signature string, now time.Time) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if now.Sub(i.cleanedAt) > i.window {
+		for item, receivedAt := range i.received {
+			if now.Sub(receivedAt) > 2*i.window {
+				delete(i.received, item)
+			}
+		}
+		i.cleanedAt = now
+	}
+	if _, ok := i.received[signature]; ok {
+		return false
+	}
+	i.received[signature] = now
+	return true
+}
+
+//	returns the signed bytes of the message, json of all fields except the signature (including the timestamp)
+func signedBytes(message models.GossipMessage) ([]byte, error) {
+	message.Signature = ""
+	return json.Marshal(message)
+}
+
+//	encrypts the message to the encryption key of its receiver with an ephemeral x25519 key and chacha20-poly1305,
+//	the message holds hex of ephemeral public key|nonce|ciphertext afterwards
+func (i *identity) Encrypt(message models.GossipMessage) (models.GossipMessage, error) {
+	receiver, ok := i.peers[message.ReceiverId]
+	if !ok {
+		return models.GossipMessage{}, fmt.Errorf(models.UnknownPeerError)
+	}
+	plaintext, err := hex.DecodeString(message.Message)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+
+	ephemeralKey := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralKey); err != nil {
+		return models.GossipMessage{}, err
+	}
+	ephemeralPub, err := curve25519.X25519(ephemeralKey, curve25519.Basepoint)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	aead, err := messageCipher(ephemeralKey, receiver.encryptionKey, ephemeralPub, receiver.encryptionKey)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return models.GossipMessage{}, err
+	}
+
+	sealed := append(append([]byte{}, ephemeralPub...), nonce...)
+	sealed = aead.Seal(sealed, nonce, plaintext, additionalData(message))
+	message.Message = hex.EncodeToString(sealed)
+	message.Encrypted = true
+	return message, nil
+}
+
+//	decrypts a message encrypted to the encryption key of the peer
+func (i *identity) Decrypt(message models.GossipMessage) (models.GossipMessage, error) {
+	sealed, err := hex.DecodeString(message.Message)
+	if err != nil || len(sealed) < curve25519.PointSize+chacha20poly1305.NonceSize {
+		return models.GossipMessage{}, fmt.Errorf(models.MessageDecryptionError)
+	}
+	ephemeralPub := sealed[:curve25519.PointSize]
+	nonce := sealed[curve25519.PointSize : curve25519.PointSize+chacha20poly1305.NonceSize]
+	ciphertext := sealed[curve25519.PointSize+chacha20poly1305.NonceSize:]
+
+	ownPub, err := curve25519.X25519(i.encryptionKey, curve25519.Basepoint)
+	if err != nil {
+		return models.GossipMessage{}, err
+	}
+	aead, err := messageCipher(i.encryptionKey, ephemeralPub, ephemeralPub, ownPub)
+	if err != nil {
+		return models.GossipMessage{}, fmt.Errorf(models.MessageDecryptionError)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(message))
+	if err != nil {
+		return models.GossipMessage{}, fmt.Errorf(models.MessageDecryptionError)
+	}
+	message.Message = hex.EncodeToString(plaintext)
+	message.Encrypted = false
+	return message, nil
+}
+
+//	derives the chacha20-poly1305 cipher of a message from the x25519 shared secret with hkdf-sha256,
+//	the public keys of both sides are the salt
+func messageCipher(privateKey []byte, publicKey []byte, ephemeralPub []byte, receiverPub []byte) (cipher.AEAD, error) {
+	shared, err := curve25519.X25519(privateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephemeralPub...), receiverPub...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(encryptionInfo)), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+//	binds the ciphertext to the messageId, sender and receiver of the message
+func additionalData(message models.GossipMessage) []byte {
+	return []byte(strings.Join([]string{message.MessageId, message.SenderId, message.ReceiverId}, "|"))
+}
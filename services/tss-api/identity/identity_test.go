@@ -0,0 +1,258 @@
+package identity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tss-identity-test")
+	if err != nil {
+		panic(err)
+	}
+	err = logger.Init(filepath.Join(dir, "tss.log"), models.Config{LogLevel: logger.ErrorLevelStr}, false)
+	if err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//	creates identities of the peers which know each other through a shared peers file
+func newTestIdentities(t *testing.T, p2pIds ...string) map[string]Identity {
+	dir := t.TempDir()
+	store, err := storage.NewStorage(models.Config{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unauthenticated := models.Config{P2pUnauthenticated: true}
+	identities := make([]models.PeerIdentity, 0)
+	for _, p2pId := range p2pIds {
+		if err = os.Mkdir(filepath.Join(dir, p2pId), 0700); err != nil {
+			t.Fatal(err)
+		}
+		id, err := NewIdentity(store, filepath.Join(dir, p2pId), unauthenticated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		identities = append(identities, models.PeerIdentity{
+			P2pId:         p2pId,
+			SigningKey:    id.SigningKey(),
+			EncryptionKey: id.EncryptionKey(),
+		})
+	}
+	bz, err := json.Marshal(identities)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peersFile := filepath.Join(dir, "peers.json")
+	if err = os.WriteFile(peersFile, bz, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// identities are loaded again from the storage, now with the peers file
+	peers := make(map[string]Identity)
+	for _, p2pId := range p2pIds {
+		id, err := NewIdentity(store, filepath.Join(dir, p2pId), models.Config{PeersFile: peersFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers[p2pId] = id
+	}
+	return peers
+}
+
+func TestNewIdentity(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewStorage(models.Config{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewIdentity(store, dir, models.Config{})
+	if err == nil || err.Error() != models.PeersFileRequiredError {
+		t.Fatalf("error = %v, want %s", err, models.PeersFileRequiredError)
+	}
+
+	first, err := NewIdentity(store, dir, models.Config{P2pUnauthenticated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Authenticated() {
+		t.Error("identity without peers file is authenticated")
+	}
+	second, err := NewIdentity(store, dir, models.Config{P2pUnauthenticated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SigningKey() != second.SigningKey() || first.EncryptionKey() != second.EncryptionKey() {
+		t.Error("identity keys are not kept in the storage")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	peers := newTestIdentities(t, "peerA", "peerB")
+	sender, receiver := peers["peerA"], peers["peerB"]
+	if !receiver.Authenticated() {
+		t.Fatal("identity with peers file is not authenticated")
+	}
+
+	tests := []struct {
+		name    string
+		sign    bool
+		tamper  func(message *models.GossipMessage)
+		wantErr string
+	}{
+		{name: "signed message", sign: true},
+		{name: "unsigned message", wantErr: models.MessageSignatureError},
+		{
+			name: "tampered message", sign: true, wantErr: models.MessageSignatureError,
+			tamper: func(message *models.GossipMessage) { message.Message = "ff" },
+		},
+		{
+			name: "tampered receiver", sign: true, wantErr: models.MessageSignatureError,
+			tamper: func(message *models.GossipMessage) { message.ReceiverId = "peerA" },
+		},
+		{
+			name: "sender posing as another peer", sign: true, wantErr: models.MessageSignatureError,
+			tamper: func(message *models.GossipMessage) { message.SenderId = "peerB" },
+		},
+		{
+			name: "unknown sender", sign: true, wantErr: models.UnknownPeerError,
+			tamper: func(message *models.GossipMessage) { message.SenderId = "peerC" },
+		},
+		{
+			name: "expired timestamp", sign: true, wantErr: models.MessageTimestampError,
+			tamper: func(message *models.GossipMessage) { message.Timestamp -= 2 * defaultWindow },
+		},
+		{
+			name: "future timestamp", sign: true, wantErr: models.MessageTimestampError,
+			tamper: func(message *models.GossipMessage) { message.Timestamp += 2 * defaultWindow },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := models.GossipMessage{MessageId: "m1", Message: "aabb", SenderId: "peerA", ReceiverId: "peerB"}
+			var err error
+			if tt.sign {
+				message, err = sender.Sign(message)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tamper != nil {
+				tt.tamper(&message)
+			}
+			err = receiver.Verify(message)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	peers := newTestIdentities(t, "peerA", "peerB")
+	message, err := peers["peerA"].Sign(models.GossipMessage{MessageId: "m1", Message: "aa", SenderId: "peerA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = peers["peerB"].Verify(message); err != nil {
+		t.Fatal(err)
+	}
+	err = peers["peerB"].Verify(message)
+	if err == nil || err.Error() != models.MessageReplayedError {
+		t.Fatalf("error = %v, want %s", err, models.MessageReplayedError)
+	}
+
+	// the same content signed again is a new message
+	time.Sleep(time.Second)
+	message, err = peers["peerA"].Sign(models.GossipMessage{MessageId: "m1", Message: "aa", SenderId: "peerA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = peers["peerB"].Verify(message); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	peers := newTestIdentities(t, "peerA", "peerB")
+	sender, receiver := peers["peerA"], peers["peerB"]
+
+	tests := []struct {
+		name    string
+		decrypt Identity
+		tamper  func(message *models.GossipMessage)
+		wantErr string
+	}{
+		{name: "receiver decrypts", decrypt: receiver},
+		{name: "other peer can not decrypt", decrypt: sender, wantErr: models.MessageDecryptionError},
+		{
+			name: "tampered ciphertext", decrypt: receiver, wantErr: models.MessageDecryptionError,
+			tamper: func(message *models.GossipMessage) {
+				last := message.Message[len(message.Message)-2:]
+				replaced := "00"
+				if last == replaced {
+					replaced = "01"
+				}
+				message.Message = message.Message[:len(message.Message)-2] + replaced
+			},
+		},
+		{
+			name: "changed messageId", decrypt: receiver, wantErr: models.MessageDecryptionError,
+			tamper: func(message *models.GossipMessage) { message.MessageId = "m2" },
+		},
+		{
+			name: "changed sender", decrypt: receiver, wantErr: models.MessageDecryptionError,
+			tamper: func(message *models.GossipMessage) { message.SenderId = "peerC" },
+		},
+		{
+			name: "short message", decrypt: receiver, wantErr: models.MessageDecryptionError,
+			tamper: func(message *models.GossipMessage) { message.Message = "aabb" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := models.GossipMessage{MessageId: "m1", Message: "a1b2c3", SenderId: "peerA", ReceiverId: "peerB"}
+			message, err := sender.Encrypt(plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !message.Encrypted || message.Message == plain.Message {
+				t.Fatal("message is not encrypted")
+			}
+			if tt.tamper != nil {
+				tt.tamper(&message)
+			}
+			decrypted, err := tt.decrypt.Decrypt(message)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decrypted.Message != plain.Message || decrypted.Encrypted {
+				t.Errorf("decrypted message = %s, want %s", decrypted.Message, plain.Message)
+			}
+		})
+	}
+
+	_, err := sender.Encrypt(models.GossipMessage{MessageId: "m1", Message: "aa", ReceiverId: "peerC"})
+	if err == nil || err.Error() != models.UnknownPeerError {
+		t.Fatalf("error = %v, want %s", err, models.UnknownPeerError)
+	}
+}
@@ -0,0 +1,176 @@
+package integrity
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+
+	"github.com/bnb-chain/tss-lib/v2/common"
+	"github.com/bnb-chain/tss-lib/v2/crypto"
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+)
+
+//	checks eddsa keygen data of a key, returns the failed checks
+func CheckEDDSA(data models.TssConfigEDDSA) []string {
+	keygenData := data.KeygenData
+	if keygenData.EDDSAPub == nil || keygenData.Xi == nil || keygenData.ShareID == nil {
+		return []string{models.EDDSANoKeygenDataFoundError}
+	}
+	errors := make([]string, 0)
+	if !checkPeersCount(data.MetaData, len(keygenData.Ks), len(keygenData.BigXj)) {
+		errors = append(errors, models.KeyPeersCountError)
+	}
+	if !CheckShare(tss.Edwards(), keygenData.Xi, keygenData.ShareID, keygenData.Ks, keygenData.BigXj) {
+		errors = append(errors, models.KeyPublicShareError)
+	}
+	if !checkInterpolation(tss.Edwards(), data.MetaData.Threshold, keygenData.Ks, keygenData.BigXj, keygenData.EDDSAPub) {
+		errors = append(errors, models.KeyInterpolationError)
+	}
+	return errors
+}
+
+//	checks ecdsa keygen data of a key, returns the failed checks and warnings of the checks which are skipped, e.g.
+//	the range proof parameters are missing in shares of older versions of tss-lib
+func CheckECDSA(data models.TssConfigECDSA) ([]string, []string) {
+	keygenData := data.KeygenData
+	if keygenData.ECDSAPub == nil || keygenData.Xi == nil || keygenData.ShareID == nil {
+		return []string{models.ECDSANoKeygenDataFoundError}, nil
+	}
+	errors := make([]string, 0)
+	warnings := make([]string, 0)
+	if !checkPeersCount(data.MetaData, len(keygenData.Ks), len(keygenData.BigXj)) ||
+		len(keygenData.NTildej) != len(keygenData.Ks) || len(keygenData.H1j) != len(keygenData.Ks) ||
+		len(keygenData.H2j) != len(keygenData.Ks) || len(keygenData.PaillierPKs) != len(keygenData.Ks) {
+		errors = append(errors, models.KeyPeersCountError)
+	}
+	if !CheckShare(tss.S256(), keygenData.Xi, keygenData.ShareID, keygenData.Ks, keygenData.BigXj) {
+		errors = append(errors, models.KeyPublicShareError)
+	}
+	if !checkInterpolation(tss.S256(), data.MetaData.Threshold, keygenData.Ks, keygenData.BigXj, keygenData.ECDSAPub) {
+		errors = append(errors, models.KeyInterpolationError)
+	}
+	consistent, complete := checkPaillier(data)
+	if !consistent {
+		errors = append(errors, models.KeyPaillierError)
+	}
+	if !complete {
+		warnings = append(warnings, models.KeyProofParamsMissingError)
+	}
+	return errors, warnings
+}
+
+//	checks number of peers in the keygen data is the peers count of meta data
+func checkPeersCount(meta models.MetaData, ks int, bigXj int) bool {
+	return meta.PeersCount > 0 && ks == meta.PeersCount && bigXj == meta.PeersCount
+}
+
+//	checks the public share of the peer in bigXj is xi*G
+func CheckShare(curve elliptic.Curve, xi *big.Int, shareID *big.Int, ks []*big.Int, bigXj []*crypto.ECPoint) bool {
+	index := utils.IndexOf(ks, shareID)
+	if index < 0 || index >= len(bigXj) || bigXj[index] == nil {
+		return false
+	}
+	return crypto.ScalarBaseMult(curve, xi).Equals(bigXj[index])
+}
+
+//	checks lagrange interpolation of the public shares at zero is the public key,
+//	both the first threshold+1 shares and all shares must give the public key
+func checkInterpolation(curve elliptic.Curve, threshold int, ks []*big.Int, bigXj []*crypto.ECPoint, pubKey *crypto.ECPoint) bool {
+	if len(ks) != len(bigXj) || threshold < 1 || threshold+1 > len(ks) {
+		return false
+	}
+	for _, count := range []int{threshold + 1, len(ks)} {
+		point, ok := interpolate(curve, ks[:count], bigXj[:count])
+		if !ok || !point.Equals(pubKey) {
+			return false
+		}
+	}
+	return true
+}
+
+//	computes sum of lambda_j * X_j, where lambda_j is the lagrange coefficient of k_j at zero
+func interpolate(curve elliptic.Curve, ks []*big.Int, bigXj []*crypto.ECPoint) (*crypto.ECPoint, bool) {
+	modQ := common.ModInt(curve.Params().N)
+	var result *crypto.ECPoint
+	for j, kj := range ks {
+		if kj == nil || bigXj[j] == nil {
+			return nil, false
+		}
+		lambda := big.NewInt(1)
+		for m, km := range ks {
+			if m == j {
+				continue
+			}
+			if km == nil || km.Cmp(kj) == 0 {
+				return nil, false
+			}
+			lambda = modQ.Mul(lambda, modQ.Mul(km, modQ.ModInverse(modQ.Sub(km, kj))))
+		}
+		term := bigXj[j].ScalarMult(lambda)
+		if result == nil {
+			result = term
+			continue
+		}
+		sum, err := result.Add(term)
+		if err != nil {
+			return nil, false
+		}
+		result = sum
+	}
+	return result, result != nil
+}
+
+//	checks paillier key and range proof parameters of the peer are consistent with each other
+//	and with the public values of the peer in the keygen data, returns whether they are consistent and whether all
+//	proof parameters are present. missing proof parameters are skipped, only present values are checked
+func checkPaillier(data models.TssConfigECDSA) (bool, bool) {
+	keygenData := data.KeygenData
+	complete := keygenData.ValidateWithProof()
+	if !keygenData.Validate() || keygenData.PaillierSK.N == nil {
+		return false, complete
+	}
+	sk := keygenData.PaillierSK
+	if sk.P != nil && sk.Q != nil && new(big.Int).Mul(sk.P, sk.Q).Cmp(sk.N) != 0 {
+		return false, complete
+	}
+
+	// NTilde is the product of the safe primes of P and Q
+	if keygenData.P != nil && keygenData.Q != nil {
+		one := big.NewInt(1)
+		safeP := new(big.Int).Add(new(big.Int).Lsh(keygenData.P, 1), one)
+		safeQ := new(big.Int).Add(new(big.Int).Lsh(keygenData.Q, 1), one)
+		if new(big.Int).Mul(safeP, safeQ).Cmp(keygenData.NTildei) != 0 {
+			return false, complete
+		}
+	}
+	if keygenData.Alpha != nil &&
+		common.ModInt(keygenData.NTildei).Exp(keygenData.H1i, keygenData.Alpha).Cmp(keygenData.H2i) != 0 {
+		return false, complete
+	}
+
+	index := utils.IndexOf(keygenData.Ks, keygenData.ShareID)
+	if index < 0 || index >= len(keygenData.PaillierPKs) || index >= len(keygenData.NTildej) ||
+		index >= len(keygenData.H1j) || index >= len(keygenData.H2j) {
+		return false, complete
+	}
+	if keygenData.PaillierPKs[index] == nil || keygenData.PaillierPKs[index].N == nil ||
+		keygenData.NTildej[index] == nil || keygenData.H1j[index] == nil || keygenData.H2j[index] == nil {
+		return false, complete
+	}
+	if keygenData.PaillierPKs[index].N.Cmp(sk.N) != 0 ||
+		keygenData.NTildej[index].Cmp(keygenData.NTildei) != 0 ||
+		keygenData.H1j[index].Cmp(keygenData.H1i) != 0 || keygenData.H2j[index].Cmp(keygenData.H2i) != 0 {
+		return false, complete
+	}
+
+	// a random message must survive encryption with the public key and decryption with the private key
+	message := common.GetRandomPositiveInt(rand.Reader, sk.N)
+	cypher, err := sk.PublicKey.Encrypt(rand.Reader, message)
+	if err != nil {
+		return false, complete
+	}
+	decrypted, err := sk.Decrypt(cypher)
+	return err == nil && decrypted.Cmp(message) == 0, complete
+}
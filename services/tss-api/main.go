@@ -3,20 +3,32 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
+	"os"
 	"rosen-bridge/tss-api/models"
 	"strings"
 
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 	"rosen-bridge/tss-api/api"
 	"rosen-bridge/tss-api/app"
+	"rosen-bridge/tss-api/identity"
 	"rosen-bridge/tss-api/logger"
 	"rosen-bridge/tss-api/network"
+	"rosen-bridge/tss-api/outbox"
+	"rosen-bridge/tss-api/preparams"
 	"rosen-bridge/tss-api/storage"
 	"rosen-bridge/tss-api/utils"
 )
 
 func main() {
 
+	// running export and import commands
+	if len(os.Args) > 1 && (os.Args[1] == exportCommand || os.Args[1] == importCommand) {
+		runBundleCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// parsing cli flags
 	projectUrl := flag.String("host", "http://localhost:4000", "project url (e.g. http://localhost:4000)")
 	guardUrl := flag.String("guardUrl", "http://localhost:8080", "guard url (e.g. http://localhost:8080)")
@@ -33,6 +45,9 @@ func main() {
 		"configFile", "./conf/conf.env", "config file",
 	)
 	trustKey := flag.String("trustKey", "", "fd010545-1f9e-41d8-8515-1094c0498073")
+	passphraseStdin := flag.Bool(
+		"passphraseStdin", false, "read passphrase of key shares from stdin, if TSS_PASSPHRASE_FILE and TSS_PASSPHRASE are not set",
+	)
 	flag.Parse()
 
 	// initiating and reading configs
@@ -64,15 +79,51 @@ func main() {
 	logging.Debugf("config: %+v", config)
 
 	if *trustKey == "" {
-		logging.Warnf("the trustKey flag is not set or is empty")
+		logging.Warnf("the trustKey flag is not set or is empty, callbacks are not signed")
+	}
+	if config.CallbackTrustKey {
+		logging.Warnf("TSS_CALLBACK_TRUST_KEY is set, the trustKey is sent in sign callbacks")
+	}
+
+	// reading passphrase of key shares
+	passphrase, err := utils.ReadPassphrase(config.PassphraseFile, *passphraseStdin)
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	// setting up authentication of the control API
+	apiSecret, err := utils.ReadApiSecret(config.ApiSecretFile)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	auth, err := api.NewAuthMiddleware(config, apiSecret)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	tlsConfig, err := api.NewTLSConfig(config)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	if config.ApiAuth == models.ApiAuthNone {
+		logging.Warn("*** control API is NOT authenticated (TSS_API_AUTH=none), anyone who reaches the API can " +
+			"sign, keygen and regroup, set TSS_API_AUTH to hmac or mtls ***")
 	}
 
 	// creating new instance of echo framework
 	e := echo.New()
 
 	// creating connection and storage and app instance
-	conn := network.InitConnection(*publishPath, *subscriptionPath, *guardUrl, *getPeerIDPath)
-	localStorage := storage.NewStorage()
+	conn := network.InitConnection(*publishPath, *subscriptionPath, *guardUrl, *getPeerIDPath, *trustKey)
+	localStorage, err := storage.NewStorage(config, passphrase)
+	if err != nil {
+		logging.Fatal(err)
+	}
+	defer func() {
+		err = localStorage.Close()
+		if err != nil {
+			logging.Error(err)
+		}
+	}()
 
 	tss := app.NewRosenTss(conn, localStorage, config, *trustKey)
 
@@ -82,8 +133,36 @@ func main() {
 		logging.Fatal(err)
 	}
 
+	// moving keygen data of the old layouts to the storage
+	err = tss.GetStorage().MigrateKeys(tss.GetPeerHome())
+	if err != nil {
+		checkPassphraseError(logging, err)
+		logging.Fatal(err)
+	}
+
+	// loading identity key of the peer and identity keys of other peers
+	peerIdentity, err := identity.NewIdentity(tss.GetStorage(), tss.GetPeerHome(), config)
+	if err != nil {
+		checkPassphraseError(logging, err)
+		logging.Fatal(err)
+	}
+	tss.SetIdentity(peerIdentity)
+
+	// delivering pending callbacks in background
+	callbackOutbox := outbox.NewOutbox(tss.GetStorage(), tss.GetPeerHome(), conn, config)
+	err = callbackOutbox.Start()
+	if err != nil {
+		logging.Fatal(err)
+	}
+	tss.SetOutbox(callbackOutbox)
+
+	// generating ecdsa pre-params in background
+	preParams := preparams.NewPreParams(tss.GetStorage(), tss.GetPeerHome(), passphrase != "", config)
+	preParams.Start()
+	tss.SetPreParams(preParams)
+
 	// subscribe to p2p
-	err = tss.GetConnection().Subscribe(*projectUrl)
+	err = tss.Subscribe(*projectUrl)
 	if err != nil {
 		logging.Fatal(err)
 	}
@@ -98,30 +177,67 @@ func main() {
 		logging.Fatal(err)
 	}
 
-	// setting up meta data if exist for eddsa
-	eddsaMetaData, _, err := tss.GetStorage().LoadEDDSAKeygen(tss.GetPeerHome(), tss.GetP2pId())
-	if err != nil {
-		logging.Warn(err)
-	}
-
-	err = tss.SetMetaData(eddsaMetaData.MetaData, models.EDDSA)
-	if err != nil {
-		logging.Warn(models.EDDSANoMetaDataFoundError)
+	// setting up meta data of stored keys
+	for _, crypto := range []string{models.EDDSA, models.ECDSA} {
+		keyIds, err := tss.GetStorage().ListKeys(tss.GetPeerHome(), crypto)
+		if err != nil {
+			logging.Warn(err)
+			continue
+		}
+		for _, keyId := range keyIds {
+			var meta models.MetaData
+			switch crypto {
+			case models.EDDSA:
+				data, _, err := tss.GetStorage().LoadEDDSAKeygen(tss.GetPeerHome(), tss.GetP2pId(), keyId)
+				if err != nil {
+					checkPassphraseError(logging, err)
+					logging.Warn(err)
+					continue
+				}
+				meta = data.MetaData
+			case models.ECDSA:
+				data, _, err := tss.GetStorage().LoadECDSAKeygen(tss.GetPeerHome(), tss.GetP2pId(), keyId)
+				if err != nil {
+					checkPassphraseError(logging, err)
+					logging.Warn(err)
+					continue
+				}
+				meta = data.MetaData
+			}
+			err = tss.SetMetaData(meta, crypto, keyId)
+			if err != nil {
+				logging.Warn(err)
+			}
+		}
+		if len(keyIds) == 0 {
+			logging.Warnf("no %s key found", crypto)
+		}
 	}
 
-	// setting up meta data if exist for ecdsa
-	ecdsaMetaData, _, err := tss.GetStorage().LoadECDSAKeygen(tss.GetPeerHome(), tss.GetP2pId())
+	// checking integrity of stored keys
+	checks, err := tss.CheckKeys("")
 	if err != nil {
 		logging.Warn(err)
 	}
-
-	err = tss.SetMetaData(ecdsaMetaData.MetaData, models.ECDSA)
-	if err != nil {
-		logging.Warn(models.ECDSANoMetaDataFoundError)
+	for _, check := range checks {
+		if !check.Valid {
+			logging.Errorf("%s key %s is corrupted, check it with GET /keys/check", check.Crypto, check.KeyId)
+		}
 	}
 
-	api.InitRouting(e, tssController)
+	api.InitRouting(e, tssController, auth)
 	hostPath := strings.ReplaceAll(*projectUrl, "https://", "")
 	hostPath = strings.ReplaceAll(hostPath, "http://", "")
+	if tlsConfig != nil {
+		logging.Fatal(e.StartServer(&http.Server{Addr: hostPath, TLSConfig: tlsConfig}))
+	}
 	logging.Fatal(e.Start(hostPath))
 }
+
+//	stops the app if key shares can not be decrypted with the passphrase
+func checkPassphraseError(logging *zap.SugaredLogger, err error) {
+	switch err.Error() {
+	case models.PassphraseRequiredError, models.WrongPassphraseError:
+		logging.Fatal(err)
+	}
+}
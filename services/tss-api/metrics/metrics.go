@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	namespace = "tss"
+)
+
+const (
+	DroppedChannelNotFound   = "channel-not-found"
+	DroppedOperationFinished = "operation-finished"
+	DroppedUnknownPeer       = "unknown-peer"
+	DroppedInvalidSignature  = "invalid-signature"
+	DroppedSenderMismatch    = "sender-mismatch"
+	DroppedDecryptionFailed  = "decryption-failed"
+	DroppedNotEncrypted      = "not-encrypted"
+)
+
+var (
+	operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "finished operations by type, crypto and outcome",
+	}, []string{"type", "crypto", "outcome"})
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "duration of operations from request to finish",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"type", "crypto", "outcome"})
+	firstMessage = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_first_message_seconds",
+		Help:      "time from request of an operation to its first peer message",
+		Buckets:   []float64{0.1, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"type", "crypto"})
+	receivedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_received_messages_total",
+		Help:      "messages received from p2p",
+	})
+	receivedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_received_bytes_total",
+		Help:      "bytes of messages received from p2p",
+	})
+	receiveErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_receive_errors_total",
+		Help:      "messages received from p2p which could not be parsed",
+	})
+	publishedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_published_messages_total",
+		Help:      "messages published to p2p",
+	})
+	publishedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_published_bytes_total",
+		Help:      "bytes of messages published to p2p",
+	})
+	publishErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "p2p_publish_errors_total",
+		Help:      "messages which could not be published to p2p",
+	})
+	callbackFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callback_failures_total",
+		Help:      "callbacks which could not be sent",
+	})
+	pendingCallbacks = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_callbacks",
+		Help:      "callbacks in the outbox waiting to be delivered",
+	})
+	channels = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "message_channels",
+		Help:      "message channels of running operations",
+	})
+	runningSigns = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "running_signs",
+		Help:      "sign operations admitted by the sign queue by crypto",
+	}, []string{"crypto"})
+	queuedSigns = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queued_signs",
+		Help:      "sign operations waiting in the sign queue by crypto",
+	}, []string{"crypto"})
+	rejectedSigns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rejected_signs_total",
+		Help:      "sign requests rejected because the sign queue was full",
+	}, []string{"crypto"})
+	droppedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_messages_total",
+		Help:      "p2p messages which were not delivered to an operation",
+	}, []string{"reason"})
+	plaintextRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "plaintext_rejected_total",
+		Help:      "plaintext key shares and identities rejected because a passphrase is set",
+	})
+)
+
+//	records a finished operation and its duration
+func OperationFinished(operationType string, crypto string, outcome string, duration time.Duration) {
+	operations.WithLabelValues(operationType, crypto, outcome).Inc()
+	operationDuration.WithLabelValues(operationType, crypto, outcome).Observe(duration.Seconds())
+}
+
+//	records the time an operation waited for its first peer message
+func OperationFirstMessage(operationType string, crypto string, wait time.Duration) {
+	firstMessage.WithLabelValues(operationType, crypto).Observe(wait.Seconds())
+}
+
+//	records a message received from p2p, err is the parse error of the message
+func MessageReceived(size int, err error) {
+	receivedMessages.Inc()
+	receivedBytes.Add(float64(size))
+	if err != nil {
+		receiveErrors.Inc()
+	}
+}
+
+//	records a message published to p2p, err is the publish error of the message
+func MessagePublished(size int, err error) {
+	publishedMessages.Inc()
+	publishedBytes.Add(float64(size))
+	if err != nil {
+		publishErrors.Inc()
+	}
+}
+
+//	records a failed callback
+func CallbackFailed() {
+	callbackFailures.Inc()
+}
+
+//	sets number of message channels
+func SetChannels(count int) {
+	channels.Set(float64(count))
+}
+
+//	sets the number of callbacks waiting in the outbox
+func SetPendingCallbacks(count int) {
+	pendingCallbacks.Set(float64(count))
+}
+
+//	records a dropped p2p message with the reason
+func MessageDropped(reason string) {
+	droppedMessages.WithLabelValues(reason).Inc()
+}
+
+//	sets the number of running and queued signs of the crypto
+func SetSignQueue(crypto string, running int, queued int) {
+	runningSigns.WithLabelValues(crypto).Set(float64(running))
+	queuedSigns.WithLabelValues(crypto).Set(float64(queued))
+}
+
+//	records a sign request rejected by the full sign queue
+func SignRejected(crypto string) {
+	rejectedSigns.WithLabelValues(crypto).Inc()
+}
+
+//	records plaintext data rejected by the storage which has a passphrase
+func PlaintextRejected() {
+	plaintextRejected.Inc()
+}
@@ -1,9 +1,12 @@
 package models
 
 import (
+	"encoding/json"
+
 	ecdsaKeygen "github.com/bnb-chain/tss-lib/v2/ecdsa/keygen"
 	eddsaKeygen "github.com/bnb-chain/tss-lib/v2/eddsa/keygen"
 	"github.com/bnb-chain/tss-lib/v2/tss"
+	"time"
 )
 
 const (
@@ -18,6 +21,60 @@ const (
 	WrongOperationError         = "wrong operation"
 	WrongCryptoProtocolError    = "wrong crypto protocol"
 	WrongDerivationPathError    = "wrong derivation path"
+	HardenedDerivationError     = "hardened derivation is not supported"
+	DerivationPathPrefixError   = "derivation path must be m or start with m/"
+	EmptyDerivationIndexError   = "derivation path has an empty index"
+	InvalidChainCodeError       = "invalid chain code"
+	NotInRegroupCommitteeError  = "peer is not in any regroup committee"
+	WrongRegroupPeersError      = "regroup peers do not match keygen data"
+	PreParamsNotReadyError      = "ecdsa pre-params are not ready"
+	PreParamsNotConfiguredError = "ecdsa pre-params are not configured"
+	SignatureVerificationError  = "signature verification failed"
+	OperationNotFoundError      = "operation not found"
+	OperationFinishedError      = "operation is finished"
+	OperationCancelledError     = "operation cancelled"
+	InvalidKeyIdError           = "invalid keyId"
+	KeyNotFoundError            = "key not found"
+	PassphraseRequiredError     = "key shares are encrypted, a passphrase is required"
+	WrongPassphraseError        = "wrong passphrase, unable to decrypt key share"
+	PlaintextDataError          = "data is not encrypted while a passphrase is set, it may be replaced"
+	EmptyPassphraseError        = "empty passphrase"
+	WrongStorageBackendError    = "wrong storage backend"
+	OperationInterruptedError   = "operation interrupted by restart"
+	BundleChecksumError         = "bundle checksum mismatch, the bundle is corrupted"
+	WrongBundleVersionError     = "unsupported bundle version"
+	WrongBundlePassphraseError  = "wrong passphrase, unable to decrypt bundle"
+	P2pIdMismatchError          = "p2pId of the bundle does not match the p2pId of the peer"
+	ShareMismatchError          = "key share does not match its public key, share id or meta data in the bundle"
+	DuplicatedBundleKeyError    = "key is repeated in the bundle"
+	KeyPeersCountError          = "keygen data does not match peers count of meta data"
+	KeyPublicShareError         = "public share of the peer does not match its secret share"
+	KeyInterpolationError       = "public shares do not interpolate to the public key"
+	KeyPaillierError            = "paillier keys are not consistent"
+	KeyProofParamsMissingError  = "range proof parameters are missing, paillier keys are partially checked"
+	UnknownPeerError            = "identity key of the peer is not registered"
+	MessageSignatureError       = "message signature verification failed"
+	MessageSenderError          = "message sender does not match sender of the party message"
+	UnknownPartyError           = "sender is not a party of the operation"
+	MessageDecryptionError      = "unable to decrypt message"
+	MessageNotEncryptedError    = "point-to-point message is not encrypted"
+	MessageTimestampError       = "message timestamp is out of the allowed window"
+	MessageReplayedError        = "message is already received"
+	PeersFileRequiredError      = "peers file is required to authenticate p2p messages"
+	WrongApiAuthError           = "wrong api auth mode"
+	ApiSecretRequiredError      = "api secret is required for hmac authentication"
+	ApiAuthRequiredError        = "request is not authenticated"
+	ApiSignatureError           = "invalid request signature"
+	ApiTimestampError           = "request timestamp is out of the allowed window"
+	ApiNonceReusedError         = "request nonce is already used"
+	CallbackNotFoundError       = "callback not found"
+	OutboxNotConfiguredError    = "callback outbox is not configured"
+	SignatureNotFoundError      = "signature not found"
+	EmptyBatchError             = "batch has no messages"
+	BatchTooLargeError          = "batch has too many messages"
+	DuplicatedBatchMessageError = "message is repeated in the batch"
+	SignQueueFullError          = "sign queue is full"
+	SignQueueTimeoutError       = "sign queue wait timeout"
 )
 
 const (
@@ -25,6 +82,46 @@ const (
 	EDDSA = "eddsa"
 )
 
+const (
+	DefaultKeyId = "default"
+)
+
+// size of the chain code of the derived public key, bytes of the chainCode string are used as it is
+const ChainCodeSize = 32
+
+const (
+	FileStorage = "file"
+	BoltStorage = "bolt"
+)
+
+const (
+	TimestampHeader = "X-Tss-Timestamp"
+	NonceHeader     = "X-Tss-Nonce"
+	SignatureHeader = "X-Tss-Signature"
+)
+
+const (
+	ApiAuthNone = "none"
+	ApiAuthHMAC = "hmac"
+	ApiAuthMTLS = "mtls"
+)
+
+const (
+	OperationQueued          = "queued"
+	OperationRunning         = "running"
+	OperationWaitingForPeers = "waiting-for-peers"
+	OperationSucceeded       = "succeeded"
+	OperationFailed          = "failed"
+	OperationTimedOut        = "timed-out"
+	OperationCancelled       = "cancelled"
+)
+
+const (
+	FailureTimeout       = "timeout"
+	FailureProtocolAbort = "protocol-abort"
+	FailureLocalError    = "local-error"
+)
+
 type KeygenMessage struct {
 	PeersCount       int      `json:"peersCount" validate:"required"`
 	Threshold        int      `json:"threshold" validate:"required"`
@@ -32,6 +129,7 @@ type KeygenMessage struct {
 	CallBackUrl      string   `json:"callBackUrl" validate:"required"`
 	P2PIDs           []string `json:"p2pIDs" validate:"required"`
 	OperationTimeout int      `json:"operationTimeout" validate:"required"`
+	KeyId            string   `json:"keyId"`
 }
 
 type SignMessage struct {
@@ -42,6 +140,38 @@ type SignMessage struct {
 	OperationTimeout int      `json:"operationTimeout" validate:"required"`
 	ChainCode        string   `json:"chainCode" validate:"required"`
 	DerivationPath   []uint32 `json:"derivationPath"`
+	KeyId            string   `json:"keyId"`
+	Priority         int      `json:"priority"`
+	QueueTimeout     int      `json:"queueTimeout"`
+	BatchId          string   `json:"-"`
+}
+
+type BatchSignItem struct {
+	Message        string   `json:"message" validate:"required"`
+	DerivationPath []uint32 `json:"derivationPath"`
+}
+
+type BatchSignMessage struct {
+	Crypto           string          `json:"crypto" validate:"required"`
+	Items            []BatchSignItem `json:"items" validate:"required,dive"`
+	CallBackUrl      string          `json:"callBackUrl" validate:"required"`
+	Peers            []Peer          `json:"peers" validate:"required"`
+	OperationTimeout int             `json:"operationTimeout" validate:"required"`
+	ChainCode        string          `json:"chainCode" validate:"required"`
+	KeyId            string          `json:"keyId"`
+	Priority         int             `json:"priority"`
+	QueueTimeout     int             `json:"queueTimeout"`
+}
+
+type RegroupMessage struct {
+	Crypto           string   `json:"crypto" validate:"required"`
+	CallBackUrl      string   `json:"callBackUrl" validate:"required"`
+	OldThreshold     int      `json:"oldThreshold" validate:"required"`
+	OldPeers         []Peer   `json:"oldPeers" validate:"required"`
+	NewThreshold     int      `json:"newThreshold" validate:"required"`
+	NewP2PIDs        []string `json:"newP2PIDs" validate:"required"`
+	OperationTimeout int      `json:"operationTimeout" validate:"required"`
+	KeyId            string   `json:"keyId"`
 }
 
 type Peer struct {
@@ -50,23 +180,99 @@ type Peer struct {
 }
 
 type SignData struct {
-	Message           string `json:"message"`
-	Signature         string `json:"signature"`
-	SignatureRecovery string `json:"signatureRecovery"`
-	Status            string `json:"status"`
-	Error             string `json:"error"`
-	TrustKey          string `json:"trustKey"`
+	Message           string             `json:"message"`
+	Signature         string             `json:"signature"`
+	SignatureRecovery string             `json:"signatureRecovery"`
+	PubKey            string             `json:"pubKey"`
+	KeyId             string             `json:"keyId"`
+	DerivationPath    []uint32           `json:"derivationPath"`
+	Status            string             `json:"status"`
+	Error             string             `json:"error"`
+	TrustKey          string             `json:"trustKey,omitempty"`
+	Progress          *OperationProgress `json:"progress,omitempty"`
+	Failure           *OperationFailure  `json:"failure,omitempty"`
+}
+
+type BatchSignData struct {
+	BatchId  string     `json:"batchId"`
+	KeyId    string     `json:"keyId"`
+	Items    []SignData `json:"items"`
+	Status   string     `json:"status"`
+	TrustKey string     `json:"trustKey,omitempty"`
 }
 
 type KeygenData struct {
+	KeyId   string `json:"keyId"`
 	ShareID string `json:"shareID"`
 	PubKey  string `json:"pubKey"`
 	Status  string `json:"status"`
 }
 
+type RegroupData struct {
+	KeyId    string             `json:"keyId"`
+	ShareID  string             `json:"shareID"`
+	PubKey   string             `json:"pubKey"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error"`
+	Progress *OperationProgress `json:"progress,omitempty"`
+	Failure  *OperationFailure  `json:"failure,omitempty"`
+}
+
+type PubKeyData struct {
+	Crypto             string   `json:"crypto"`
+	KeyId              string   `json:"keyId"`
+	ChainCode          string   `json:"chainCode"`
+	DerivationPath     []uint32 `json:"derivationPath"`
+	PubKey             string   `json:"pubKey"`
+	UncompressedPubKey string   `json:"uncompressedPubKey,omitempty"`
+	ExtendedPubKey     string   `json:"extendedPubKey"`
+}
+
+type KeyInfo struct {
+	Crypto     string `json:"crypto"`
+	KeyId      string `json:"keyId"`
+	PubKey     string `json:"pubKey"`
+	ShareID    string `json:"shareID"`
+	PeersCount int    `json:"peersCount"`
+	Threshold  int    `json:"threshold"`
+}
+
 type FailKeygenData struct {
-	Status string `json:"status"`
-	Error  string `json:"error"`
+	KeyId    string             `json:"keyId"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error"`
+	Progress *OperationProgress `json:"progress,omitempty"`
+	Failure  *OperationFailure  `json:"failure,omitempty"`
+}
+
+type OperationProgress struct {
+	Round         int                  `json:"round"`
+	WaitingFor    []string             `json:"waitingFor"`
+	LastMessageAt map[string]time.Time `json:"lastMessageAt"`
+	BytesSent     int64                `json:"bytesSent"`
+	BytesReceived int64                `json:"bytesReceived"`
+}
+
+type OperationFailure struct {
+	Kind       string   `json:"kind"`
+	Round      int      `json:"round"`
+	Culprits   []Peer   `json:"culprits"`
+	WaitingFor []string `json:"waitingFor"`
+}
+
+type Operation struct {
+	Id            string             `json:"id"`
+	Type          string             `json:"type"`
+	Crypto        string             `json:"crypto"`
+	KeyId         string             `json:"keyId"`
+	State         string             `json:"state"`
+	Error         string             `json:"error,omitempty"`
+	Result        interface{}        `json:"result,omitempty"`
+	QueuePosition int                `json:"queuePosition,omitempty"`
+	Progress      *OperationProgress `json:"progress,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt"`
+	FinishedAt    *time.Time         `json:"finishedAt,omitempty"`
 }
 
 type Message struct {
@@ -80,6 +286,15 @@ type GossipMessage struct {
 	Message    string `json:"message"`
 	SenderId   string `json:"senderId"`
 	ReceiverId string `json:"receiverId"`
+	Encrypted  bool   `json:"encrypted,omitempty"`
+	Timestamp  int64  `json:"timestamp,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+type PeerIdentity struct {
+	P2pId         string `json:"p2pId"`
+	SigningKey    string `json:"signingKey"`
+	EncryptionKey string `json:"encryptionKey"`
 }
 
 type MetaData struct {
@@ -114,19 +329,90 @@ type PartyMessage struct {
 }
 
 type Config struct {
-	HomeAddress                string  `mapstructure:"TSS_HOME_ADDRESS"`
-	LogAddress                 string  `mapstructure:"TSS_LOG_ADDRESS"`
-	LogLevel                   string  `mapstructure:"TSS_LOG_LEVEL"`
-	LogMaxSize                 int     `mapstructure:"TSS_LOG_MAX_SIZE"`
-	LogMaxBackups              int     `mapstructure:"TSS_LOG_MAX_BACKUPS"`
-	LogMaxAge                  int     `mapstructure:"TSS_LOG_MAX_AGE"`
-	MessageTimeout             int     `mapstructure:"TSS_MESSAGE_TIMEOUT"`
-	WriteMsgRetryTime          int     `mapstructure:"TSS_WRITE_MSG_RETRY_TIME"`
-	LeastProcessRemainingTime  int64   `mapstructure:"TSS_LEAST_PROCESS_REMAINING_TIME"`
-	SetupBroadcastInterval     int64   `mapstructure:"TSS_SETUP_BROADCAST_INTERVAL"`
-	SignStartTimeTracker       float64 `mapstructure:"TSS_SIGN_START_TIME_TRACKER"`
-	TurnDuration               int64   `mapstructure:"TSS_TURN_DURATION"`
-	WaitInPartyMessageHandling int64   `mapstructure:"TSS_WAIT_IN_PARTY_MESSAGE_HANDLING"`
+	HomeAddress               string  `mapstructure:"TSS_HOME_ADDRESS"`
+	LogAddress                string  `mapstructure:"TSS_LOG_ADDRESS"`
+	LogLevel                  string  `mapstructure:"TSS_LOG_LEVEL"`
+	LogMaxSize                int     `mapstructure:"TSS_LOG_MAX_SIZE"`
+	LogMaxBackups             int     `mapstructure:"TSS_LOG_MAX_BACKUPS"`
+	LogMaxAge                 int     `mapstructure:"TSS_LOG_MAX_AGE"`
+	MessageTimeout            int     `mapstructure:"TSS_MESSAGE_TIMEOUT"`
+	WriteMsgRetryTime         int     `mapstructure:"TSS_WRITE_MSG_RETRY_TIME"`
+	LeastProcessRemainingTime int64   `mapstructure:"TSS_LEAST_PROCESS_REMAINING_TIME"`
+	SetupBroadcastInterval    int64   `mapstructure:"TSS_SETUP_BROADCAST_INTERVAL"`
+	SignStartTimeTracker      float64 `mapstructure:"TSS_SIGN_START_TIME_TRACKER"`
+	TurnDuration              int64   `mapstructure:"TSS_TURN_DURATION"`
+	PreParamsPoolSize         int     `mapstructure:"TSS_PRE_PARAMS_POOL_SIZE"`
+	PreParamsTimeout          int     `mapstructure:"TSS_PRE_PARAMS_TIMEOUT"`
+	OperationRetention        int     `mapstructure:"TSS_OPERATION_RETENTION"`
+	PassphraseFile            string  `mapstructure:"TSS_PASSPHRASE_FILE"`
+	StorageBackend            string  `mapstructure:"TSS_STORAGE_BACKEND"`
+	PeersFile                 string  `mapstructure:"TSS_PEERS_FILE"`
+	P2pUnauthenticated        bool    `mapstructure:"TSS_P2P_UNAUTHENTICATED"`
+	MessageSignatureWindow    int     `mapstructure:"TSS_MESSAGE_SIGNATURE_WINDOW"`
+	ApiAuth                   string  `mapstructure:"TSS_API_AUTH"`
+	ApiSecretFile             string  `mapstructure:"TSS_API_SECRET_FILE"`
+	ApiAuthWindow             int     `mapstructure:"TSS_API_AUTH_WINDOW"`
+	TLSCertFile               string  `mapstructure:"TSS_TLS_CERT_FILE"`
+	TLSKeyFile                string  `mapstructure:"TSS_TLS_KEY_FILE"`
+	TLSClientCAFile           string  `mapstructure:"TSS_TLS_CLIENT_CA_FILE"`
+	CallbackTrustKey          bool    `mapstructure:"TSS_CALLBACK_TRUST_KEY"`
+	CallbackRetryInterval     int     `mapstructure:"TSS_CALLBACK_RETRY_INTERVAL"`
+	CallbackMaxRetryInterval  int     `mapstructure:"TSS_CALLBACK_MAX_RETRY_INTERVAL"`
+	SignatureCacheTTL         int     `mapstructure:"TSS_SIGNATURE_CACHE_TTL"`
+	SignBatchMaxSize          int     `mapstructure:"TSS_SIGN_BATCH_MAX_SIZE"`
+	ECDSASignConcurrency      int     `mapstructure:"TSS_ECDSA_SIGN_CONCURRENCY"`
+	EDDSASignConcurrency      int     `mapstructure:"TSS_EDDSA_SIGN_CONCURRENCY"`
+	SignQueueSize             int     `mapstructure:"TSS_SIGN_QUEUE_SIZE"`
+	SignQueueTimeout          int     `mapstructure:"TSS_SIGN_QUEUE_TIMEOUT"`
+}
+
+type Callback struct {
+	Id            string          `json:"id"`
+	Url           string          `json:"url"`
+	Data          json.RawMessage `json:"data"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+}
+
+type SignatureRecord struct {
+	Hash      string    `json:"hash"`
+	Crypto    string    `json:"crypto"`
+	ChainCode string    `json:"chainCode"`
+	SignData  SignData  `json:"signData"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type KeyCheck struct {
+	Crypto    string    `json:"crypto"`
+	KeyId     string    `json:"keyId"`
+	Valid     bool      `json:"valid"`
+	Errors    []string  `json:"errors,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+type KeyStatus struct {
+	KeyId    string `json:"keyId"`
+	MetaData bool   `json:"metaData"`
+}
+
+type Readiness struct {
+	Ready             bool                   `json:"ready"`
+	Subscribed        bool                   `json:"subscribed"`
+	P2pId             string                 `json:"p2pId"`
+	Keys              map[string][]KeyStatus `json:"keys"`
+	PreParams         PreParamsStatus        `json:"preParams"`
+	RunningOperations int                    `json:"runningOperations"`
+}
+
+type PreParamsStatus struct {
+	Ready      bool `json:"ready"`
+	Available  int  `json:"available"`
+	PoolSize   int  `json:"poolSize"`
+	Generating bool `json:"generating"`
 }
 
 type Payload struct {
@@ -2,12 +2,18 @@ package network
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
 	"rosen-bridge/tss-api/models"
 )
 
@@ -26,12 +32,20 @@ type connect struct {
 	publishUrl      string
 	subscriptionUrl string
 	getPeerIDUrl    string
+	callbackKey     []byte
 	Client          HTTPClient
 }
 
+const (
+	// requests to the guard and callbacks are abandoned after this many seconds, so a hanging receiver can not block
+	// the caller forever
+	requestTimeout = 30
+)
+
 var logging *zap.SugaredLogger
 
-func InitConnection(publishPath string, subscriptionPath string, guardUrl string, getPeerIDPath string) Connection {
+//	Constructor of a connection, callbacks are signed with the callback key if it is not empty
+func InitConnection(publishPath string, subscriptionPath string, guardUrl string, getPeerIDPath string, callbackKey string) Connection {
 	publishUrl := fmt.Sprintf("%s%s", guardUrl, publishPath)
 	subscriptionUrl := fmt.Sprintf("%s%s", guardUrl, subscriptionPath)
 	getPeerIDUrl := fmt.Sprintf("%s%s", guardUrl, getPeerIDPath)
@@ -40,15 +54,19 @@ func InitConnection(publishPath string, subscriptionPath string, guardUrl string
 		publishUrl:      publishUrl,
 		subscriptionUrl: subscriptionUrl,
 		getPeerIDUrl:    getPeerIDUrl,
-		Client:          &http.Client{},
+		callbackKey:     []byte(callbackKey),
+		Client:          &http.Client{Timeout: time.Second * requestTimeout},
 	}
 
 }
 
 //	publishes a message to p2p
-func (c *connect) Publish(msg models.GossipMessage) error {
+func (c *connect) Publish(msg models.GossipMessage) (err error) {
 	logging.Infof("publishing new message on p2p")
 	marshalledMessage, _ := json.Marshal(&msg)
+	defer func() {
+		metrics.MessagePublished(len(marshalledMessage), err)
+	}()
 
 	type message struct {
 		Message  string `json:"message"`
@@ -152,8 +170,13 @@ func (c *connect) Subscribe(projectUrl string) error {
 }
 
 //	sends sign data to this url
-func (c *connect) CallBack(url string, data interface{}) error {
+func (c *connect) CallBack(url string, data interface{}) (err error) {
 	logging.Info("sending callback data")
+	defer func() {
+		if err != nil {
+			metrics.CallbackFailed()
+		}
+	}()
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -166,6 +189,11 @@ func (c *connect) CallBack(url string, data interface{}) error {
 		return err
 	}
 	req.Header.Add("content-type", "application/json")
+	if len(c.callbackKey) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Add(models.TimestampHeader, timestamp)
+		req.Header.Add(models.SignatureHeader, CallbackSignature(c.callbackKey, timestamp, jsonData))
+	}
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -181,6 +209,15 @@ func (c *connect) CallBack(url string, data interface{}) error {
 	return nil
 }
 
+//	returns hex of hmac-sha256 of the callback with the key, over the timestamp and the body separated by a new line
+func CallbackSignature(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 //	to get p2pId
 func (c *connect) GetPeerId() (string, error) {
 	logging.Infof("Getting PeerId")
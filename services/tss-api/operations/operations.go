@@ -0,0 +1,449 @@
+package operations
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+const (
+	defaultRetention = 3600
+)
+
+//	round in the protobuf type of tss-lib messages, e.g. binance.tsslib.ecdsa.signing.SignRound2Message1
+var roundPattern = regexp.MustCompile(`Round(\d+)Message`)
+
+//	order of non-terminal states, an operation never goes back to a lower state
+var stateRank = map[string]int{
+	models.OperationQueued:          0,
+	models.OperationWaitingForPeers: 1,
+	models.OperationRunning:         2,
+}
+
+type Registry interface {
+	Add(operationType string, crypto string, keyId string, messageId string) string
+	Register(id string, operationType string, crypto string, keyId string, messageId string)
+	SetState(id string, state string)
+	Running(messageId string)
+	SetResult(messageId string, result interface{})
+	MessageReceived(messageId string, peer string, size int)
+	MessageSent(messageId string, size int)
+	Round(messageId string, msg tss.Message)
+	Progress(messageId string, parties ...tss.Party)
+	Finish(id string, err error)
+	Timeout(id string)
+	Cancel(id string) error
+	Cancelled(id string) <-chan struct{}
+	Done(id string) <-chan struct{}
+	Get(id string) (models.Operation, error)
+	List() []models.Operation
+	Restore(store storage.Storage, peerHome string) error
+}
+
+type record struct {
+	operation models.Operation
+	messageId string
+	cancel    chan struct{}
+	done      chan struct{}
+}
+
+type registry struct {
+	lock       sync.Mutex
+	operations map[string]*record
+	retention  time.Duration
+	store      storage.Storage
+	peerHome   string
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of an operation registry, finished operations are kept for the retention window of config
+func NewRegistry(config models.Config) Registry {
+	logging = logger.NewSugar("operations")
+	retention := config.OperationRetention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	return &registry{
+		operations: make(map[string]*record),
+		retention:  time.Second * time.Duration(retention),
+	}
+}
+
+//	returns true if the state is a final one
+func isFinished(state string) bool {
+	_, ok := stateRank[state]
+	return !ok
+}
+
+//	returns a new operation id
+func NewId() string {
+	return xid.New().String()
+}
+
+//	registers a new queued operation and returns its id
+func (r *registry) Add(operationType string, crypto string, keyId string, messageId string) string {
+	id := NewId()
+	r.Register(id, operationType, crypto, keyId, messageId)
+	return id
+}
+
+//	registers a new queued operation with the id, for operations which need their id before they are registered
+func (r *registry) Register(id string, operationType string, crypto string, keyId string, messageId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cleanup()
+
+	now := time.Now()
+	r.operations[id] = &record{
+		operation: models.Operation{
+			Id:        id,
+			Type:      operationType,
+			Crypto:    crypto,
+			KeyId:     keyId,
+			State:     models.OperationQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		messageId: messageId,
+		cancel:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	r.save(r.operations[id])
+	logging.Debugf("operation %s registered for %s %s of key %s", id, crypto, operationType, keyId)
+}
+
+//	moves a running operation forward to the given state
+func (r *registry) SetState(id string, state string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item, ok := r.operations[id]; ok {
+		r.advance(item, state)
+	}
+}
+
+//	marks the unfinished operation of the messageId as running, called when a peer message is received.
+//	a queued operation stays queued, its messages are kept until it is started
+func (r *registry) Running(messageId string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil && item.operation.State != models.OperationQueued {
+		if item.operation.State != models.OperationRunning {
+			metrics.OperationFirstMessage(item.operation.Type, item.operation.Crypto, time.Since(item.operation.CreatedAt))
+		}
+		r.advance(item, models.OperationRunning)
+	}
+}
+
+//	sets the result of the unfinished operation of the messageId
+func (r *registry) SetResult(messageId string, result interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil {
+		item.operation.Result = result
+		item.operation.UpdatedAt = time.Now()
+		r.save(item)
+	}
+}
+
+//	records a message of the peer delivered to the unfinished operation of the messageId
+func (r *registry) MessageReceived(messageId string, peer string, size int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil {
+		progress := item.progress()
+		progress.LastMessageAt[peer] = time.Now()
+		progress.BytesReceived += int64(size)
+		item.operation.Progress = progress
+	}
+}
+
+//	records a message published by the unfinished operation of the messageId
+func (r *registry) MessageSent(messageId string, size int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil {
+		progress := item.progress()
+		progress.BytesSent += int64(size)
+		item.operation.Progress = progress
+	}
+}
+
+//	records the round of a message sent by a party of the unfinished operation of the messageId. a party sends the
+//	messages of a round when it starts the round, so the highest round of its messages is its current round
+func (r *registry) Round(messageId string, msg tss.Message) {
+	match := roundPattern.FindStringSubmatch(msg.Type())
+	if match == nil {
+		return
+	}
+	round, err := strconv.Atoi(match[1])
+	if err != nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil && round > progressRound(item) {
+		progress := item.progress()
+		progress.Round = round
+		item.operation.Progress = progress
+	}
+}
+
+//	returns the recorded round of the operation
+func progressRound(item *record) int {
+	if item.operation.Progress == nil {
+		return 0
+	}
+	return item.operation.Progress.Round
+}
+
+//	records the peers which the parties of the unfinished operation of the messageId are waiting for, a regroup has a
+//	party for each committee of the peer
+func (r *registry) Progress(messageId string, parties ...tss.Party) {
+	waitingFor := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, party := range parties {
+		if party == nil {
+			continue
+		}
+		for _, partyId := range party.WaitingFor() {
+			if !seen[partyId.Id] {
+				seen[partyId.Id] = true
+				waitingFor = append(waitingFor, partyId.Id)
+			}
+		}
+	}
+	sort.Strings(waitingFor)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.find(messageId); item != nil {
+		progress := item.progress()
+		progress.WaitingFor = waitingFor
+		item.operation.Progress = progress
+	}
+}
+
+//	finishes the operation as succeeded or failed, a timed-out or cancelled operation keeps its state
+func (r *registry) Finish(id string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	item, ok := r.operations[id]
+	if !ok || item.operation.FinishedAt != nil {
+		return
+	}
+	state := item.operation.State
+	if err != nil {
+		item.operation.Error = err.Error()
+		if !isFinished(state) {
+			state = models.OperationFailed
+		}
+	} else if !isFinished(state) {
+		state = models.OperationSucceeded
+	}
+	r.finish(item, state)
+	metrics.OperationFinished(item.operation.Type, item.operation.Crypto, state, item.operation.FinishedAt.Sub(item.operation.CreatedAt))
+}
+
+//	marks an unfinished operation as timed-out
+func (r *registry) Timeout(id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item, ok := r.operations[id]; ok && !isFinished(item.operation.State) {
+		r.stop(item, models.OperationTimedOut)
+	}
+}
+
+//	marks an unfinished operation as cancelled and notifies its watchers
+func (r *registry) Cancel(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	item, ok := r.operations[id]
+	if !ok {
+		return fmt.Errorf(models.OperationNotFoundError)
+	}
+	if isFinished(item.operation.State) {
+		return fmt.Errorf(models.OperationFinishedError)
+	}
+	r.stop(item, models.OperationCancelled)
+	close(item.cancel)
+	return nil
+}
+
+//	returns a channel which is closed when the operation is cancelled
+func (r *registry) Cancelled(id string) <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item, ok := r.operations[id]; ok {
+		return item.cancel
+	}
+	return nil
+}
+
+//	returns a channel which is closed when the operation is finished
+func (r *registry) Done(id string) <-chan struct{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item, ok := r.operations[id]; ok {
+		return item.done
+	}
+	return nil
+}
+
+//	returns the operation with the given id
+func (r *registry) Get(id string) (models.Operation, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cleanup()
+	item, ok := r.operations[id]
+	if !ok {
+		return models.Operation{}, fmt.Errorf(models.OperationNotFoundError)
+	}
+	return item.operation, nil
+}
+
+//	returns all operations ordered by creation time
+func (r *registry) List() []models.Operation {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cleanup()
+	operations := make([]models.Operation, 0, len(r.operations))
+	for _, item := range r.operations {
+		operations = append(operations, item.operation)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		return operations[i].CreatedAt.Before(operations[j].CreatedAt)
+	})
+	return operations
+}
+
+//	returns a copy of progress of the operation, the progress of an operation is replaced instead of being changed
+//	since copies of the operation are returned by the registry. progress is stored with the next state change
+func (item *record) progress() *models.OperationProgress {
+	progress := &models.OperationProgress{
+		WaitingFor:    make([]string, 0),
+		LastMessageAt: make(map[string]time.Time),
+	}
+	if current := item.operation.Progress; current != nil {
+		*progress = *current
+		progress.LastMessageAt = make(map[string]time.Time, len(current.LastMessageAt))
+		for peer, at := range current.LastMessageAt {
+			progress.LastMessageAt[peer] = at
+		}
+	}
+	return progress
+}
+
+//	finds the unfinished operation of the messageId
+func (r *registry) find(messageId string) *record {
+	for _, item := range r.operations {
+		if item.messageId == messageId && !isFinished(item.operation.State) {
+			return item
+		}
+	}
+	return nil
+}
+
+//	changes state of an unfinished operation if the new state is after the current one
+func (r *registry) advance(item *record, state string) {
+	if isFinished(item.operation.State) || stateRank[state] <= stateRank[item.operation.State] {
+		return
+	}
+	item.operation.State = state
+	item.operation.UpdatedAt = time.Now()
+	r.save(item)
+	logging.Debugf("operation %s is %s", item.operation.Id, state)
+}
+
+//	sets a final state of an operation which is still being stopped
+func (r *registry) stop(item *record, state string) {
+	item.operation.State = state
+	item.operation.UpdatedAt = time.Now()
+	r.save(item)
+	logging.Infof("operation %s is %s", item.operation.Id, state)
+}
+
+//	sets the final state of an operation and notifies its watchers
+func (r *registry) finish(item *record, state string) {
+	now := time.Now()
+	item.operation.State = state
+	item.operation.UpdatedAt = now
+	item.operation.FinishedAt = &now
+	r.save(item)
+	close(item.done)
+	logging.Infof("operation %s %s", item.operation.Id, state)
+}
+
+//	removes finished operations older than the retention window
+func (r *registry) cleanup() {
+	for id, item := range r.operations {
+		if item.operation.FinishedAt != nil && time.Since(*item.operation.FinishedAt) > r.retention {
+			delete(r.operations, id)
+			if r.store != nil {
+				if err := r.store.DeleteOperation(r.peerHome, id); err != nil {
+					logging.Warnf("unable to remove operation %s from storage, err: %+v", id, err)
+				}
+			}
+		}
+	}
+}
+
+//	writes the operation to the storage if the registry is restored from one
+func (r *registry) save(item *record) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.SaveOperation(r.peerHome, item.operation); err != nil {
+		logging.Warnf("unable to store operation %s, err: %+v", item.operation.Id, err)
+	}
+}
+
+//	loads the operation history of the storage and keeps it updated from now on,
+//	operations which were not finished before the restart are marked as failed
+func (r *registry) Restore(store storage.Storage, peerHome string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.store = store
+	r.peerHome = peerHome
+	operations, err := store.LoadOperations(peerHome)
+	if err != nil {
+		return err
+	}
+	for _, operation := range operations {
+		if _, ok := r.operations[operation.Id]; ok {
+			continue
+		}
+		item := &record{
+			operation: operation,
+			cancel:    make(chan struct{}),
+			done:      make(chan struct{}),
+		}
+		r.operations[operation.Id] = item
+		if operation.FinishedAt == nil {
+			item.operation.Error = models.OperationInterruptedError
+			if !isFinished(operation.State) {
+				item.operation.State = models.OperationFailed
+			}
+			r.finish(item, item.operation.State)
+			continue
+		}
+		close(item.done)
+	}
+	r.cleanup()
+	if len(operations) > 0 {
+		logging.Infof("%d operations restored from storage", len(operations))
+	}
+	return nil
+}
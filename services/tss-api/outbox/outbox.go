@@ -0,0 +1,279 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/network"
+	"rosen-bridge/tss-api/storage"
+)
+
+const (
+	defaultRetryInterval    = 1
+	defaultMaxRetryInterval = 300
+)
+
+type Outbox interface {
+	Start() error
+	Send(url string, data interface{}) error
+	List() []models.Callback
+	Retry(id string) error
+	Delete(id string) error
+}
+
+type outbox struct {
+	lock      sync.Mutex
+	callbacks map[string]*models.Callback
+	// destinations which have a delivery in progress, callbacks of each destination are delivered in order
+	sending          map[string]bool
+	notify           chan struct{}
+	store            storage.Storage
+	peerHome         string
+	connection       network.Connection
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of a callback outbox, callbacks are kept in the storage and retried with exponential backoff
+//	until they are delivered
+func NewOutbox(store storage.Storage, peerHome string, connection network.Connection, config models.Config) Outbox {
+	logging = logger.NewSugar("outbox")
+	retryInterval := config.CallbackRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+	maxRetryInterval := config.CallbackMaxRetryInterval
+	if maxRetryInterval < retryInterval {
+		maxRetryInterval = defaultMaxRetryInterval
+	}
+	return &outbox{
+		callbacks:        make(map[string]*models.Callback),
+		sending:          make(map[string]bool),
+		notify:           make(chan struct{}, 1),
+		store:            store,
+		peerHome:         peerHome,
+		connection:       connection,
+		retryInterval:    time.Second * time.Duration(retryInterval),
+		maxRetryInterval: time.Second * time.Duration(maxRetryInterval),
+	}
+}
+
+//	loads pending callbacks of the storage and starts delivering them in background
+func (o *outbox) Start() error {
+	callbacks, err := o.store.LoadCallbacks(o.peerHome)
+	if err != nil {
+		return err
+	}
+	o.lock.Lock()
+	for i := range callbacks {
+		callback := callbacks[i]
+		o.callbacks[callback.Id] = &callback
+	}
+	metrics.SetPendingCallbacks(len(o.callbacks))
+	o.lock.Unlock()
+	if len(callbacks) > 0 {
+		logging.Infof("%d pending callbacks loaded", len(callbacks))
+	}
+	go o.run()
+	return nil
+}
+
+//	stores the callback and wakes up the delivery, returns error if the callback can not be stored
+func (o *outbox) Send(url string, data interface{}) error {
+	bz, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	callback := &models.Callback{
+		Id:            xid.New().String(),
+		Url:           url,
+		Data:          bz,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	o.lock.Lock()
+	err = o.store.SaveCallback(o.peerHome, *callback)
+	if err != nil {
+		o.lock.Unlock()
+		logging.Errorf("unable to store callback to %s, err: %+v", url, err)
+		return err
+	}
+	o.callbacks[callback.Id] = callback
+	metrics.SetPendingCallbacks(len(o.callbacks))
+	o.lock.Unlock()
+	o.wakeUp()
+	return nil
+}
+
+//	returns pending callbacks sorted by creation time
+func (o *outbox) List() []models.Callback {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.sorted()
+}
+
+//	returns pending callbacks sorted by creation time, the lock must be held
+func (o *outbox) sorted() []models.Callback {
+	callbacks := make([]models.Callback, 0, len(o.callbacks))
+	for _, callback := range o.callbacks {
+		callbacks = append(callbacks, *callback)
+	}
+	sort.Slice(callbacks, func(i, j int) bool {
+		return callbacks[i].CreatedAt.Before(callbacks[j].CreatedAt)
+	})
+	return callbacks
+}
+
+//	delivers the pending callback now, without waiting for its backoff
+func (o *outbox) Retry(id string) error {
+	o.lock.Lock()
+	callback, ok := o.callbacks[id]
+	if !ok {
+		o.lock.Unlock()
+		return fmt.Errorf(models.CallbackNotFoundError)
+	}
+	callback.NextAttemptAt = time.Now().UTC()
+	o.save(callback)
+	o.lock.Unlock()
+	o.wakeUp()
+	return nil
+}
+
+//	drops the pending callback, it is not delivered anymore
+func (o *outbox) Delete(id string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if _, ok := o.callbacks[id]; !ok {
+		return fmt.Errorf(models.CallbackNotFoundError)
+	}
+	o.remove(id)
+	logging.Infof("callback %s dropped", id)
+	return nil
+}
+
+//	wakes up the delivery loop if it is waiting
+func (o *outbox) wakeUp() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+//	delivers due callbacks, then waits until the next callback is due or a callback is added
+func (o *outbox) run() {
+	for {
+		wait := o.deliver()
+		select {
+		case <-o.notify:
+		case <-time.After(wait):
+		}
+	}
+}
+
+//	starts delivering due callbacks of each destination which has no delivery in progress, returns the time to the
+//	next due callback of those destinations. destinations are delivered concurrently, so a hanging receiver does
+//	not hold back callbacks of the others
+func (o *outbox) deliver() time.Duration {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	now := time.Now().UTC()
+	wait := o.maxRetryInterval
+	due := make(map[string][]string)
+	for _, callback := range o.sorted() {
+		if o.sending[callback.Url] {
+			continue
+		}
+		if next := callback.NextAttemptAt.Sub(now); next > 0 {
+			if next < wait {
+				wait = next
+			}
+			continue
+		}
+		due[callback.Url] = append(due[callback.Url], callback.Id)
+	}
+	for url, ids := range due {
+		o.sending[url] = true
+		go o.deliverTo(url, ids)
+	}
+	return wait
+}
+
+//	delivers the callbacks of the destination in order, each failed callback is retried after its own backoff.
+//	the delivery loop is woken up when they are done, to schedule the next attempts of the destination
+func (o *outbox) deliverTo(url string, ids []string) {
+	defer func() {
+		o.lock.Lock()
+		delete(o.sending, url)
+		o.lock.Unlock()
+		o.wakeUp()
+	}()
+
+	for _, id := range ids {
+		o.lock.Lock()
+		item, ok := o.callbacks[id]
+		if !ok {
+			o.lock.Unlock()
+			continue
+		}
+		data := item.Data
+		o.lock.Unlock()
+
+		err := o.connection.CallBack(url, data)
+		o.lock.Lock()
+		item, ok = o.callbacks[id]
+		if !ok {
+			o.lock.Unlock()
+			continue
+		}
+		if err == nil {
+			o.remove(id)
+			logging.Infof("callback %s delivered after %d attempts", id, item.Attempts+1)
+		} else {
+			item.Attempts++
+			item.LastError = err.Error()
+			item.NextAttemptAt = time.Now().UTC().Add(o.backoff(item.Attempts))
+			o.save(item)
+			logging.Warnf("callback %s to %s failed, attempt %d, next attempt at %s", item.Id, item.Url, item.Attempts, item.NextAttemptAt)
+		}
+		o.lock.Unlock()
+	}
+}
+
+//	returns the wait before the next attempt, doubled on each failed attempt up to the max retry interval
+func (o *outbox) backoff(attempts int) time.Duration {
+	wait := o.retryInterval
+	for i := 1; i < attempts && wait < o.maxRetryInterval; i++ {
+		wait *= 2
+	}
+	if wait > o.maxRetryInterval {
+		wait = o.maxRetryInterval
+	}
+	return wait
+}
+
+//	writes the callback to the storage
+func (o *outbox) save(callback *models.Callback) {
+	if err := o.store.SaveCallback(o.peerHome, *callback); err != nil {
+		logging.Warnf("unable to store callback %s, err: %+v", callback.Id, err)
+	}
+}
+
+//	removes the callback from the outbox and the storage
+func (o *outbox) remove(id string) {
+	delete(o.callbacks, id)
+	if err := o.store.DeleteCallback(o.peerHome, id); err != nil {
+		logging.Warnf("unable to remove callback %s from storage, err: %+v", id, err)
+	}
+	metrics.SetPendingCallbacks(len(o.callbacks))
+}
@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tss-outbox-test")
+	if err != nil {
+		panic(err)
+	}
+	err = logger.Init(filepath.Join(dir, "tss.log"), models.Config{LogLevel: logger.ErrorLevelStr}, false)
+	if err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//	connection which fails the first callbacks and records the delivered ones
+type testConnection struct {
+	lock      sync.Mutex
+	failures  int
+	attempts  int
+	delivered []string
+}
+
+func (c *testConnection) Publish(models.GossipMessage) error {
+	return nil
+}
+
+func (c *testConnection) Subscribe(string) error {
+	return nil
+}
+
+func (c *testConnection) GetPeerId() (string, error) {
+	return "peer", nil
+}
+
+func (c *testConnection) CallBack(url string, data interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.attempts++
+	if c.attempts <= c.failures {
+		return fmt.Errorf("receiver is down")
+	}
+	c.delivered = append(c.delivered, fmt.Sprintf("%s", data))
+	return nil
+}
+
+func (c *testConnection) result() (int, []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.attempts, append([]string{}, c.delivered...)
+}
+
+//	creates an outbox on a temp peer home with the retry intervals
+func newTestOutbox(t *testing.T, connection *testConnection, retryInterval time.Duration, maxRetryInterval time.Duration) (*outbox, storage.Storage, string) {
+	peerHome := t.TempDir()
+	store, err := storage.NewStorage(models.Config{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := NewOutbox(store, peerHome, connection, models.Config{}).(*outbox)
+	o.retryInterval = retryInterval
+	o.maxRetryInterval = maxRetryInterval
+	return o, store, peerHome
+}
+
+//	waits until the condition is true or fails the test
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	o, _, _ := newTestOutbox(t, &testConnection{}, time.Second, 10*time.Second)
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 5, want: 10 * time.Second},
+		{attempts: 50, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := o.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxRetry(t *testing.T) {
+	connection := &testConnection{failures: 2}
+	o, store, peerHome := newTestOutbox(t, connection, 20*time.Millisecond, 100*time.Millisecond)
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Send("http://receiver", "signed"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed attempts are kept with their error until the callback is delivered
+	waitFor(t, func() bool {
+		callbacks := o.List()
+		return len(callbacks) == 1 && callbacks[0].Attempts == 2
+	})
+	callback := o.List()[0]
+	if callback.LastError != "receiver is down" {
+		t.Errorf("last error = %s", callback.LastError)
+	}
+	waitFor(t, func() bool {
+		return len(o.List()) == 0
+	})
+	attempts, delivered := connection.result()
+	if attempts != 3 || len(delivered) != 1 || delivered[0] != `"signed"` {
+		t.Errorf("attempts = %d, delivered = %v", attempts, delivered)
+	}
+	stored, err := store.LoadCallbacks(peerHome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 0 {
+		t.Errorf("delivered callback is kept in the storage")
+	}
+}
+
+func TestOutboxStart(t *testing.T) {
+	connection := &testConnection{}
+	o, store, peerHome := newTestOutbox(t, connection, 20*time.Millisecond, 100*time.Millisecond)
+	now := time.Now().UTC()
+	for i, data := range []string{`"first"`, `"second"`} {
+		err := store.SaveCallback(peerHome, models.Callback{
+			Id:            fmt.Sprintf("c%d", i),
+			Url:           "http://receiver",
+			Data:          []byte(data),
+			CreatedAt:     now.Add(time.Duration(i) * time.Second),
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pending callbacks of the storage are delivered in order after a restart
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return len(o.List()) == 0
+	})
+	_, delivered := connection.result()
+	if len(delivered) != 2 || delivered[0] != `"first"` || delivered[1] != `"second"` {
+		t.Errorf("delivered = %v", delivered)
+	}
+}
+
+func TestOutboxRetryNowAndDelete(t *testing.T) {
+	connection := &testConnection{failures: 1}
+	o, _, _ := newTestOutbox(t, connection, time.Hour, time.Hour)
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Send("http://receiver", "first"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		callbacks := o.List()
+		return len(callbacks) == 1 && callbacks[0].Attempts == 1
+	})
+
+	// the backoff of an hour is skipped by retry
+	if err := o.Retry(o.List()[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return len(o.List()) == 0
+	})
+
+	connection.lock.Lock()
+	connection.failures = 10
+	connection.lock.Unlock()
+	if err := o.Send("http://receiver", "second"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		callbacks := o.List()
+		return len(callbacks) == 1 && callbacks[0].Attempts == 1
+	})
+	if err := o.Delete(o.List()[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.List()) != 0 {
+		t.Error("deleted callback is pending")
+	}
+	for _, err := range []error{o.Retry("unknown"), o.Delete("unknown")} {
+		if err == nil || err.Error() != models.CallbackNotFoundError {
+			t.Errorf("error = %v, want %s", err, models.CallbackNotFoundError)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package preparams
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	ecdsaKeygen "github.com/bnb-chain/tss-lib/v2/ecdsa/keygen"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+const (
+	defaultPoolSize = 1
+	defaultTimeout  = 600
+	retryInterval   = 10
+)
+
+type PreParams interface {
+	Start()
+	Status() models.PreParamsStatus
+	Pop() (*ecdsaKeygen.LocalPreParams, error)
+}
+
+type entry struct {
+	id        string
+	preParams *ecdsaKeygen.LocalPreParams
+}
+
+type preParams struct {
+	lock       sync.Mutex
+	pool       []entry
+	generating bool
+	notify     chan struct{}
+	store      storage.Storage
+	peerHome   string
+	encrypted  bool
+	poolSize   int
+	timeout    time.Duration
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of an ecdsa pre-params pool, pre-params are kept in the storage only if it encrypts them with the
+//	passphrase of key shares
+func NewPreParams(store storage.Storage, peerHome string, encrypted bool, config models.Config) PreParams {
+	logging = logger.NewSugar("pre-params")
+	poolSize := config.PreParamsPoolSize
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+	timeout := config.PreParamsTimeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &preParams{
+		notify:    make(chan struct{}, 1),
+		store:     store,
+		peerHome:  peerHome,
+		encrypted: encrypted,
+		poolSize:  poolSize,
+		timeout:   time.Second * time.Duration(timeout),
+	}
+}
+
+//	loads stored pre-params and starts generating the missing ones in background
+func (p *preParams) Start() {
+	if !p.encrypted {
+		logging.Warn("no passphrase is set, ecdsa pre-params are kept in memory only")
+	} else {
+		p.load()
+	}
+	go p.generate()
+}
+
+//	returns status of the pool
+func (p *preParams) Status() models.PreParamsStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return models.PreParamsStatus{
+		Ready:      len(p.pool) > 0,
+		Available:  len(p.pool),
+		PoolSize:   p.poolSize,
+		Generating: p.generating,
+	}
+}
+
+//	takes a pre-params out of the pool, each pre-params is used only once
+func (p *preParams) Pop() (*ecdsaKeygen.LocalPreParams, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.pool) == 0 {
+		return nil, fmt.Errorf(models.PreParamsNotReadyError)
+	}
+	item := p.pool[0]
+	p.pool = p.pool[1:]
+	if item.id != "" {
+		if err := p.store.DeletePreParams(p.peerHome, item.id); err != nil {
+			logging.Warnf("unable to remove pre-params %s, err: %+v", item.id, err)
+		}
+	}
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+	return item.preParams, nil
+}
+
+//	loads stored pre-params, invalid ones (e.g. pre-params encrypted with the trustKey by older versions) are removed
+func (p *preParams) load() {
+	items, err := p.store.LoadPreParams(p.peerHome)
+	if err != nil {
+		logging.Warnf("unable to load stored ecdsa pre-params, err: %+v", err)
+		return
+	}
+	for id, data := range items {
+		item := ecdsaKeygen.LocalPreParams{}
+		if err = json.Unmarshal(data, &item); err != nil || !item.ValidateWithProof() {
+			logging.Warnf("invalid pre-params %s is removed", id)
+			if err = p.store.DeletePreParams(p.peerHome, id); err != nil {
+				logging.Warnf("unable to remove pre-params %s, err: %+v", id, err)
+			}
+			continue
+		}
+		p.pool = append(p.pool, entry{id: id, preParams: &item})
+	}
+	logging.Infof("%d ecdsa pre-params loaded", len(p.pool))
+}
+
+//	stores pre-params with a new id, the storage encrypts them
+func (p *preParams) save(item *ecdsaKeygen.LocalPreParams) (string, error) {
+	if !p.encrypted {
+		return "", nil
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	id := xid.New().String()
+	if err = p.store.SavePreParams(p.peerHome, id, data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+//	keeps the pool full, waits for a pop when there is nothing to generate
+func (p *preParams) generate() {
+	for {
+		p.lock.Lock()
+		full := len(p.pool) >= p.poolSize
+		p.generating = !full
+		p.lock.Unlock()
+		if full {
+			<-p.notify
+			continue
+		}
+
+		logging.Info("generating ecdsa pre-params")
+		start := time.Now()
+		item, err := ecdsaKeygen.GeneratePreParams(p.timeout)
+		if err != nil {
+			logging.Errorf("unable to generate ecdsa pre-params, err: %+v", err)
+			time.Sleep(time.Second * retryInterval)
+			continue
+		}
+		id, err := p.save(item)
+		if err != nil {
+			logging.Errorf("unable to store ecdsa pre-params, err: %+v", err)
+		}
+		p.lock.Lock()
+		p.pool = append(p.pool, entry{id: id, preParams: item})
+		p.lock.Unlock()
+		logging.Infof("ecdsa pre-params generated in %v", time.Since(start))
+	}
+}
@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
+	"rosen-bridge/tss-api/models"
+)
+
+const (
+	defaultECDSAConcurrency = 4
+	defaultEDDSAConcurrency = 16
+	defaultSize             = 64
+)
+
+type Queue interface {
+	Add(crypto string, operationId string, priority int, reserved bool) (<-chan struct{}, error)
+	Done(crypto string, operationId string)
+	Position(operationId string) int
+	Reserve(crypto string, count int) error
+	Release(crypto string, count int)
+}
+
+//	a sign waiting for a slot of its crypto
+type ticket struct {
+	operationId string
+	priority    int
+	admitted    chan struct{}
+}
+
+//	running signs and the queue of a crypto, queued signs are ordered by priority and then by arrival, reserved places
+//	are kept for signs of a batch which are not added yet
+type slots struct {
+	limit    int
+	running  int
+	reserved int
+	queued   []*ticket
+}
+
+type queue struct {
+	lock    sync.Mutex
+	size    int
+	cryptos map[string]*slots
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of a sign queue, at most the concurrency of config signs of each crypto run at once and up to the
+//	queue size of config signs of each crypto wait for a slot
+func NewQueue(config models.Config) Queue {
+	logging = logger.NewSugar("queue")
+	ecdsaConcurrency := config.ECDSASignConcurrency
+	if ecdsaConcurrency <= 0 {
+		ecdsaConcurrency = defaultECDSAConcurrency
+	}
+	eddsaConcurrency := config.EDDSASignConcurrency
+	if eddsaConcurrency <= 0 {
+		eddsaConcurrency = defaultEDDSAConcurrency
+	}
+	size := config.SignQueueSize
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &queue{
+		size: size,
+		cryptos: map[string]*slots{
+			models.ECDSA: {limit: ecdsaConcurrency},
+			models.EDDSA: {limit: eddsaConcurrency},
+		},
+	}
+}
+
+//	admits the sign if a slot of the crypto is free or queues it, returns a channel which is closed when the sign is
+//	admitted. returns SignQueueFullError if the queue of the crypto is full, a reserved sign takes one of the reserved
+//	places instead
+func (q *queue) Add(crypto string, operationId string, priority int, reserved bool) (<-chan struct{}, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	s, ok := q.cryptos[crypto]
+	if !ok {
+		return nil, fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	if reserved && s.reserved > 0 {
+		s.reserved--
+	} else if q.free(s) <= 0 {
+		metrics.SignRejected(crypto)
+		return nil, fmt.Errorf(models.SignQueueFullError)
+	}
+	item := &ticket{
+		operationId: operationId,
+		priority:    priority,
+		admitted:    make(chan struct{}),
+	}
+	if s.running < s.limit && len(s.queued) == 0 {
+		s.running++
+		close(item.admitted)
+		q.report(crypto, s)
+		return item.admitted, nil
+	}
+
+	index := len(s.queued)
+	for i, queued := range s.queued {
+		if priority > queued.priority {
+			index = i
+			break
+		}
+	}
+	s.queued = append(s.queued, nil)
+	copy(s.queued[index+1:], s.queued[index:])
+	s.queued[index] = item
+	q.report(crypto, s)
+	logging.Infof("sign operation %s is queued at position %d of %s", operationId, index+1, crypto)
+	return item.admitted, nil
+}
+
+//	releases the slot of a finished sign, or removes it from the queue if it is not admitted, and admits the next
+//	queued signs
+func (q *queue) Done(crypto string, operationId string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	s, ok := q.cryptos[crypto]
+	if !ok {
+		return
+	}
+	removed := false
+	for i, queued := range s.queued {
+		if queued.operationId == operationId {
+			s.queued = append(s.queued[:i], s.queued[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	if !removed && s.running > 0 {
+		s.running--
+	}
+	for s.running < s.limit && len(s.queued) > 0 {
+		next := s.queued[0]
+		s.queued = s.queued[1:]
+		s.running++
+		close(next.admitted)
+		logging.Infof("sign operation %s is admitted", next.operationId)
+	}
+	q.report(crypto, s)
+}
+
+//	returns position of the queued sign starting from 1, 0 if it is not queued
+func (q *queue) Position(operationId string) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, s := range q.cryptos {
+		for i, queued := range s.queued {
+			if queued.operationId == operationId {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+//	reserves places for count signs of the crypto at once, so all signs of a batch can be added or none of them.
+//	returns SignQueueFullError if they do not fit
+func (q *queue) Reserve(crypto string, count int) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	s, ok := q.cryptos[crypto]
+	if !ok {
+		return fmt.Errorf(models.WrongCryptoProtocolError)
+	}
+	if q.free(s) < count {
+		metrics.SignRejected(crypto)
+		return fmt.Errorf(models.SignQueueFullError)
+	}
+	s.reserved += count
+	return nil
+}
+
+//	releases reserved places of signs which are not added
+func (q *queue) Release(crypto string, count int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	s, ok := q.cryptos[crypto]
+	if !ok {
+		return
+	}
+	s.reserved -= count
+	if s.reserved < 0 {
+		s.reserved = 0
+	}
+}
+
+//	returns the number of signs which can be added, free slots and free places of the queue which are not reserved,
+//	the lock must be held
+func (q *queue) free(s *slots) int {
+	free := q.size - len(s.queued)
+	if len(s.queued) == 0 {
+		free += s.limit - s.running
+	}
+	return free - s.reserved
+}
+
+//	updates metrics of the crypto, the lock must be held
+func (q *queue) report(crypto string, s *slots) {
+	metrics.SetSignQueue(crypto, s.running, len(s.queued))
+}
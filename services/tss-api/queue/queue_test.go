@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tss-queue-test")
+	if err != nil {
+		panic(err)
+	}
+	err = logger.Init(filepath.Join(dir, "tss.log"), models.Config{LogLevel: logger.ErrorLevelStr}, false)
+	if err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+//	returns true if the sign is admitted
+func admitted(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+//	adds the sign and fails the test on error
+func mustAdd(t *testing.T, q Queue, operationId string, priority int, reserved bool) <-chan struct{} {
+	ch, err := q.Add(models.EDDSA, operationId, priority, reserved)
+	if err != nil {
+		t.Fatalf("unable to add %s: %v", operationId, err)
+	}
+	return ch
+}
+
+func TestQueueLimit(t *testing.T) {
+	q := NewQueue(models.Config{EDDSASignConcurrency: 2, SignQueueSize: 1})
+	first := mustAdd(t, q, "s1", 0, false)
+	second := mustAdd(t, q, "s2", 0, false)
+	third := mustAdd(t, q, "s3", 0, false)
+	if !admitted(first) || !admitted(second) {
+		t.Fatal("signs within the concurrency are not admitted")
+	}
+	if admitted(third) {
+		t.Fatal("sign over the concurrency is admitted")
+	}
+	if _, err := q.Add(models.EDDSA, "s4", 0, false); err == nil || err.Error() != models.SignQueueFullError {
+		t.Fatalf("error = %v, want %s", err, models.SignQueueFullError)
+	}
+	// the queue of each crypto is separate
+	if ch, err := q.Add(models.ECDSA, "e1", 0, false); err != nil || !admitted(ch) {
+		t.Fatalf("ecdsa sign is not admitted: %v", err)
+	}
+	if _, err := q.Add("rsa", "r1", 0, false); err == nil || err.Error() != models.WrongCryptoProtocolError {
+		t.Fatalf("error = %v, want %s", err, models.WrongCryptoProtocolError)
+	}
+
+	q.Done(models.EDDSA, "s1")
+	if !admitted(third) {
+		t.Fatal("queued sign is not admitted after a sign is done")
+	}
+	if position := q.Position("s3"); position != 0 {
+		t.Errorf("position of the admitted sign = %d, want 0", position)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	q := NewQueue(models.Config{EDDSASignConcurrency: 1, SignQueueSize: 8})
+	mustAdd(t, q, "running", 0, false)
+	tests := []struct {
+		operationId string
+		priority    int
+	}{
+		{operationId: "low1", priority: 0},
+		{operationId: "high1", priority: 5},
+		{operationId: "low2", priority: 0},
+		{operationId: "mid", priority: 2},
+		{operationId: "high2", priority: 5},
+	}
+	channels := make(map[string]<-chan struct{})
+	for _, tt := range tests {
+		channels[tt.operationId] = mustAdd(t, q, tt.operationId, tt.priority, false)
+	}
+
+	// higher priority first, then by arrival
+	order := []string{"high1", "high2", "mid", "low1", "low2"}
+	for i, operationId := range order {
+		if position := q.Position(operationId); position != i+1 {
+			t.Errorf("position of %s = %d, want %d", operationId, position, i+1)
+		}
+	}
+	previous := "running"
+	for _, operationId := range order {
+		q.Done(models.EDDSA, previous)
+		if !admitted(channels[operationId]) {
+			t.Fatalf("%s is not admitted after %s", operationId, previous)
+		}
+		previous = operationId
+	}
+}
+
+func TestQueueDoneQueued(t *testing.T) {
+	q := NewQueue(models.Config{EDDSASignConcurrency: 1, SignQueueSize: 2})
+	mustAdd(t, q, "running", 0, false)
+	cancelled := mustAdd(t, q, "cancelled", 0, false)
+	next := mustAdd(t, q, "next", 0, false)
+
+	// a queued sign which is done leaves the queue without taking the slot
+	q.Done(models.EDDSA, "cancelled")
+	if admitted(cancelled) || admitted(next) {
+		t.Fatal("sign is admitted while the slot is taken")
+	}
+	if position := q.Position("next"); position != 1 {
+		t.Errorf("position of next = %d, want 1", position)
+	}
+	q.Done(models.EDDSA, "running")
+	if !admitted(next) {
+		t.Fatal("next sign is not admitted")
+	}
+}
+
+func TestQueueReserve(t *testing.T) {
+	q := NewQueue(models.Config{EDDSASignConcurrency: 1, SignQueueSize: 2})
+	tests := []struct {
+		name    string
+		count   int
+		wantErr bool
+	}{
+		{name: "reserve the free places", count: 2},
+		{name: "reserve over the free places", count: 2, wantErr: true},
+		{name: "reserve the last place", count: 1},
+		{name: "reserve a full queue", count: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := q.Reserve(models.EDDSA, tt.count)
+		if tt.wantErr && (err == nil || err.Error() != models.SignQueueFullError) {
+			t.Fatalf("%s: error = %v, want %s", tt.name, err, models.SignQueueFullError)
+		}
+		if !tt.wantErr && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+
+	// places are reserved for the batch, other signs are rejected
+	if _, err := q.Add(models.EDDSA, "other", 0, false); err == nil || err.Error() != models.SignQueueFullError {
+		t.Fatalf("error = %v, want %s", err, models.SignQueueFullError)
+	}
+	first := mustAdd(t, q, "item1", 0, true)
+	second := mustAdd(t, q, "item2", 0, true)
+	if !admitted(first) || admitted(second) {
+		t.Fatal("reserved signs are not admitted in order")
+	}
+
+	// the place of an item which is not added is released
+	q.Release(models.EDDSA, 1)
+	if ch, err := q.Add(models.EDDSA, "other", 0, false); err != nil || admitted(ch) {
+		t.Fatalf("sign is not queued on the released place: %v", err)
+	}
+	if _, err := q.Add(models.EDDSA, "another", 0, false); err == nil {
+		t.Fatal("sign is added to the full queue")
+	}
+}
@@ -0,0 +1,134 @@
+package signatures
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/storage"
+)
+
+const (
+	defaultTTL = 86400
+)
+
+type Cache interface {
+	Add(hash string, crypto string, chainCode string, data models.SignData)
+	Get(hash string) (models.SignatureRecord, error)
+	Purge(crypto string, keyId string)
+	Restore(store storage.Storage, peerHome string) error
+}
+
+type cache struct {
+	lock     sync.Mutex
+	records  map[string]models.SignatureRecord
+	ttl      time.Duration
+	store    storage.Storage
+	peerHome string
+}
+
+var logging *zap.SugaredLogger
+
+//	Constructor of a signature cache, completed signatures are kept for the ttl of config
+func NewCache(config models.Config) Cache {
+	logging = logger.NewSugar("signatures")
+	ttl := config.SignatureCacheTTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &cache{
+		records: make(map[string]models.SignatureRecord),
+		ttl:     time.Second * time.Duration(ttl),
+	}
+}
+
+//	keeps the signature of a completed sign, the trust key is never cached
+func (c *cache) Add(hash string, crypto string, chainCode string, data models.SignData) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cleanup()
+
+	data.TrustKey = ""
+	now := time.Now().UTC()
+	record := models.SignatureRecord{
+		Hash:      hash,
+		Crypto:    crypto,
+		ChainCode: chainCode,
+		SignData:  data,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.ttl),
+	}
+	c.records[hash] = record
+	if c.store != nil {
+		if err := c.store.SaveSignature(c.peerHome, record); err != nil {
+			logging.Warnf("unable to store signature %s, err: %+v", hash, err)
+		}
+	}
+	logging.Debugf("signature %s cached until %s", hash, record.ExpiresAt)
+}
+
+//	returns the cached signature of the hash
+func (c *cache) Get(hash string) (models.SignatureRecord, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cleanup()
+	record, ok := c.records[hash]
+	if !ok {
+		return models.SignatureRecord{}, fmt.Errorf(models.SignatureNotFoundError)
+	}
+	return record, nil
+}
+
+//	removes cached signatures of the key, they are not valid for a key which is archived or regrouped
+func (c *cache) Purge(crypto string, keyId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for hash, record := range c.records {
+		if record.Crypto == crypto && record.SignData.KeyId == keyId {
+			c.remove(hash)
+		}
+	}
+	logging.Debugf("signatures of %s key %s are purged", crypto, keyId)
+}
+
+//	removes expired signatures, the lock must be held
+func (c *cache) cleanup() {
+	now := time.Now()
+	for hash, record := range c.records {
+		if now.After(record.ExpiresAt) {
+			c.remove(hash)
+		}
+	}
+}
+
+//	removes the signature from memory and the storage, the lock must be held
+func (c *cache) remove(hash string) {
+	delete(c.records, hash)
+	if c.store != nil {
+		if err := c.store.DeleteSignature(c.peerHome, hash); err != nil {
+			logging.Warnf("unable to remove signature %s from storage, err: %+v", hash, err)
+		}
+	}
+}
+
+//	loads cached signatures of the storage and keeps it updated from now on
+func (c *cache) Restore(store storage.Storage, peerHome string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.store = store
+	c.peerHome = peerHome
+	records, err := store.LoadSignatures(peerHome)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if _, ok := c.records[record.Hash]; !ok {
+			c.records[record.Hash] = record
+		}
+	}
+	c.cleanup()
+	return nil
+}
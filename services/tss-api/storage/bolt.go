@@ -0,0 +1,709 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	boltFileName    = "tss.db"
+	boltOpenTimeout = 5
+	migratedSuffix  = ".migrated"
+)
+
+var (
+	keysBucket       = []byte("keys")
+	archiveBucket    = []byte("archive")
+	operationsBucket = []byte("operations")
+	preParamsBucket  = []byte("preParams")
+	infoBucket       = []byte("info")
+	callbacksBucket  = []byte("callbacks")
+	signaturesBucket = []byte("signatures")
+	fileMigratedKey  = []byte("fileMigrated")
+	identityKey      = []byte("identity")
+)
+
+type boltStorage struct {
+	lock       sync.Mutex
+	passphrase string
+	decrypter  *utils.Decrypter
+	db         *bolt.DB
+}
+
+//	Constructor of a bolt storage, all data of the peer is kept in <home>/tss.db and every change is a single transaction
+func newBoltStorage(passphrase string) Storage {
+	return &boltStorage{
+		passphrase: passphrase,
+		decrypter:  newDecrypter(passphrase),
+	}
+}
+
+//	opens the database of the peer home on first use and creates the buckets
+func (b *boltStorage) open(peerHome string) (*bolt.DB, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	path := filepath.Join(peerHome, boltFileName)
+	if b.db != nil {
+		if b.db.Path() != path {
+			return nil, fmt.Errorf("storage database is already opened at %s", b.db.Path())
+		}
+		return b.db, nil
+	}
+	err := os.MkdirAll(peerHome, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second * boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open storage database %s, err:{%v}", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{keysBucket, archiveBucket, operationsBucket, preParamsBucket, infoBucket, callbacksBucket, signaturesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	logging.Infof("storage database opened: %s", path)
+	b.db = db
+	return db, nil
+}
+
+//	returns the bucket of the protocol in the root bucket, it is created in writable transactions
+func protocolBucket(tx *bolt.Tx, root []byte, protocol string) (*bolt.Bucket, error) {
+	if tx.Writable() {
+		return tx.Bucket(root).CreateBucketIfNotExists([]byte(protocol))
+	}
+	return tx.Bucket(root).Bucket([]byte(protocol)), nil
+}
+
+//	returns a copy of the keygen data of the key, nil if it does not exist
+func (b *boltStorage) readKey(peerHome string, protocol string, keyId string) ([]byte, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	var bz []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket, _ := protocolBucket(tx, keysBucket, protocol)
+		if bucket == nil {
+			return nil
+		}
+		if value := bucket.Get([]byte(keyId)); value != nil {
+			bz = append([]byte{}, value...)
+		}
+		return nil
+	})
+	return bz, err
+}
+
+// WriteData writing keygen data of the key to the database, data is encrypted if the storage has a passphrase
+func (b *boltStorage) WriteData(data interface{}, peerHome string, fileFormat string, protocol string, keyId string) error {
+
+	logging.Info("writing data to the storage database")
+
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(&data)
+	if err != nil {
+		return fmt.Errorf("unable to marshal data, err:{%v}", err)
+	}
+	if b.passphrase != "" {
+		bz, err = encrypt(bz, b.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := protocolBucket(tx, keysBucket, protocol)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(keyId), bz)
+	})
+	if err != nil {
+		return err
+	}
+	logging.Infof("%s keygen data of key %s was written successfully", protocol, keyId)
+	return nil
+}
+
+//	writes keygen data of all keys to the database in a single transaction, so no key is written if one fails
+func (b *boltStorage) WriteKeys(keys []KeyData, peerHome string, fileFormat string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		bz, err := json.Marshal(&key.Data)
+		if err != nil {
+			return fmt.Errorf("unable to marshal data, err:{%v}", err)
+		}
+		if b.passphrase != "" {
+			bz, err = encrypt(bz, b.passphrase)
+			if err != nil {
+				return err
+			}
+		}
+		values[i] = bz
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for i, key := range keys {
+			bucket, err := protocolBucket(tx, keysBucket, key.Protocol)
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put([]byte(key.KeyId), values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logging.Infof("keygen data of %d keys was written successfully", len(keys))
+	return nil
+}
+
+//	Loads the EDDSA keygen data of the key from the database
+func (b *boltStorage) LoadEDDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigEDDSA, *tss.PartyID, error) {
+	bz, err := b.readKey(peerHome, models.EDDSA, keyId)
+	if err != nil {
+		return models.TssConfigEDDSA{}, nil, err
+	}
+	if bz == nil {
+		logging.Warnf("couldn't find eddsa keygen of key %s", keyId)
+		return models.TssConfigEDDSA{}, nil, errors.New(models.EDDSANoKeygenDataFoundError)
+	}
+	bz, err = decrypt(bz, b.decrypter)
+	if err != nil {
+		return models.TssConfigEDDSA{}, nil, err
+	}
+	tssConfig, partyID, err := ParseEDDSAKeygen(bz, p2pId)
+	if err != nil {
+		return models.TssConfigEDDSA{}, nil, errors.Wrapf(err, "could not unmarshal eddsa keygen data of key %s", keyId)
+	}
+	return tssConfig, partyID, nil
+}
+
+//	Loads the ECDSA keygen data of the key from the database
+func (b *boltStorage) LoadECDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigECDSA, *tss.PartyID, error) {
+	bz, err := b.readKey(peerHome, models.ECDSA, keyId)
+	if err != nil {
+		return models.TssConfigECDSA{}, nil, err
+	}
+	if bz == nil {
+		logging.Warnf("couldn't find ecdsa keygen of key %s", keyId)
+		return models.TssConfigECDSA{}, nil, errors.New(models.ECDSANoKeygenDataFoundError)
+	}
+	bz, err = decrypt(bz, b.decrypter)
+	if err != nil {
+		return models.TssConfigECDSA{}, nil, err
+	}
+	tssConfig, partyID, err := ParseECDSAKeygen(bz, p2pId)
+	if err != nil {
+		return models.TssConfigECDSA{}, nil, errors.Wrapf(err, "could not unmarshal ecdsa keygen data of key %s", keyId)
+	}
+	return tssConfig, partyID, nil
+}
+
+//	returns keyIds of the stored keys of the protocol
+func (b *boltStorage) ListKeys(peerHome string, protocol string) ([]string, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	keyIds := make([]string, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket, _ := protocolBucket(tx, keysBucket, protocol)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			keyIds = append(keyIds, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyIds, nil
+}
+
+//	moves the key to the archive bucket, returns the archived path in the database
+func (b *boltStorage) ArchiveKey(peerHome string, protocol string, keyId string) (string, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%d", keyId, time.Now().Unix())
+	err = db.Update(func(tx *bolt.Tx) error {
+		keys, err := protocolBucket(tx, keysBucket, protocol)
+		if err != nil {
+			return err
+		}
+		value := keys.Get([]byte(keyId))
+		if value == nil {
+			return errors.New(models.KeyNotFoundError)
+		}
+		archive, err := protocolBucket(tx, archiveBucket, protocol)
+		if err != nil {
+			return err
+		}
+		if err = archive.Put([]byte(name), value); err != nil {
+			return err
+		}
+		return keys.Delete([]byte(keyId))
+	})
+	if err != nil {
+		return "", err
+	}
+	archivePath := fmt.Sprintf("%s:%s/%s/%s", filepath.Join(peerHome, boltFileName), archiveDir, protocol, name)
+	logging.Infof("%s key %s archived in %s", protocol, keyId, archivePath)
+	return archivePath, nil
+}
+
+//	imports keys, pre-params and the identity of the file layout into the database once, then encrypts plaintext data
+func (b *boltStorage) MigrateKeys(peerHome string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	migrated := false
+	err = db.View(func(tx *bolt.Tx) error {
+		migrated = tx.Bucket(infoBucket).Get(fileMigratedKey) != nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !migrated {
+		err = b.migrateFiles(db, peerHome)
+		if err != nil {
+			return err
+		}
+	}
+	if b.passphrase != "" {
+		return b.encryptKeys(db)
+	}
+	return nil
+}
+
+//	copies keygen files, pre-params files and the identity file into the database in a single transaction,
+//	the migrated files and directories are renamed with the .migrated suffix afterwards
+func (b *boltStorage) migrateFiles(db *bolt.DB, peerHome string) error {
+	files := &fileStorage{passphrase: b.passphrase, decrypter: b.decrypter}
+	err := files.MigrateKeys(peerHome)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]map[string][]byte)
+	for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+		keyIds, err := files.ListKeys(peerHome, protocol)
+		if err != nil {
+			return err
+		}
+		keys[protocol] = make(map[string][]byte)
+		for _, keyId := range keyIds {
+			bz, err := ioutil.ReadFile(files.keygenFilePath(peerHome, protocol, keyId))
+			if err != nil {
+				return err
+			}
+			keys[protocol][keyId] = bz
+		}
+	}
+	preParams, err := files.preParamsFiles(peerHome)
+	if err != nil {
+		return err
+	}
+	identity, err := ioutil.ReadFile(filepath.Join(peerHome, identityFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for protocol, items := range keys {
+			bucket, err := protocolBucket(tx, keysBucket, protocol)
+			if err != nil {
+				return err
+			}
+			for keyId, bz := range items {
+				if bucket.Get([]byte(keyId)) != nil {
+					logging.Warnf("%s key %s exists in the database, its file is not migrated", protocol, keyId)
+					continue
+				}
+				if err = bucket.Put([]byte(keyId), bz); err != nil {
+					return err
+				}
+				logging.Infof("%s key %s migrated to the database", protocol, keyId)
+			}
+		}
+		bucket := tx.Bucket(preParamsBucket)
+		for id, bz := range preParams {
+			if err := bucket.Put([]byte(id), bz); err != nil {
+				return err
+			}
+		}
+		if identity != nil && tx.Bucket(infoBucket).Get(identityKey) == nil {
+			if err := tx.Bucket(infoBucket).Put(identityKey, identity); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(infoBucket).Put(fileMigratedKey, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{models.EDDSA, models.ECDSA, preParamsDir, identityFile} {
+		path := filepath.Join(peerHome, dir)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := os.Rename(path, path+migratedSuffix); err != nil {
+			logging.Warnf("unable to rename migrated directory %s, err: %+v", path, err)
+		}
+	}
+	return nil
+}
+
+//	encrypts plaintext keygen data of all keys, pre-params and the identity with the passphrase in a single transaction
+func (b *boltStorage) encryptKeys(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if identity := tx.Bucket(infoBucket).Get(identityKey); identity != nil && encryptedContent(identity) == nil {
+			bz, err := encrypt(identity, b.passphrase)
+			if err != nil {
+				return err
+			}
+			if err = tx.Bucket(infoBucket).Put(identityKey, bz); err != nil {
+				return err
+			}
+			logging.Info("plaintext identity is encrypted")
+		}
+		err := b.encryptBucket(tx.Bucket(preParamsBucket))
+		if err != nil {
+			return err
+		}
+		for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+			bucket, err := protocolBucket(tx, keysBucket, protocol)
+			if err != nil {
+				return err
+			}
+			plain := make(map[string][]byte)
+			err = bucket.ForEach(func(k, v []byte) error {
+				if encryptedContent(v) == nil {
+					plain[string(k)] = append([]byte{}, v...)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for keyId, bz := range plain {
+				bz, err = encrypt(bz, b.passphrase)
+				if err != nil {
+					return err
+				}
+				if err = bucket.Put([]byte(keyId), bz); err != nil {
+					return err
+				}
+				logging.Infof("plaintext %s keygen data of key %s is encrypted", protocol, keyId)
+			}
+		}
+		return nil
+	})
+}
+
+//	encrypts plaintext values of the bucket with the passphrase
+func (b *boltStorage) encryptBucket(bucket *bolt.Bucket) error {
+	plain := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		if encryptedContent(v) == nil {
+			plain[string(k)] = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id, bz := range plain {
+		bz, err = encrypt(bz, b.passphrase)
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put([]byte(id), bz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//	writes the operation to the operation history
+func (b *boltStorage) SaveOperation(peerHome string, operation models.Operation) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).Put([]byte(operation.Id), bz)
+	})
+}
+
+//	reads all operations of the operation history
+func (b *boltStorage) LoadOperations(peerHome string) ([]models.Operation, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	operations := make([]models.Operation, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).ForEach(func(k, v []byte) error {
+			var operation models.Operation
+			if err := json.Unmarshal(v, &operation); err != nil {
+				logging.Warnf("invalid operation %s in the storage database, err: %+v", string(k), err)
+				return nil
+			}
+			operations = append(operations, operation)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+//	removes the operation from the operation history
+func (b *boltStorage) DeleteOperation(peerHome string, id string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(operationsBucket).Delete([]byte(id))
+	})
+}
+
+//	writes the pre-params to the database, they are encrypted if the storage has a passphrase
+func (b *boltStorage) SavePreParams(peerHome string, id string, data []byte) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	if b.passphrase != "" {
+		data, err = encrypt(data, b.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(preParamsBucket).Put([]byte(id), data)
+	})
+}
+
+//	reads and decrypts all stored pre-params, the map is keyed by the pre-params id
+func (b *boltStorage) LoadPreParams(peerHome string) (map[string][]byte, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte)
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(preParamsBucket).ForEach(func(k, v []byte) error {
+			data, err := decrypt(v, b.decrypter)
+			if err != nil {
+				logging.Warnf("unable to decrypt pre-params %s, err: %+v", string(k), err)
+				return nil
+			}
+			items[string(k)] = append([]byte{}, data...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+//	removes the pre-params from the database
+func (b *boltStorage) DeletePreParams(peerHome string, id string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(preParamsBucket).Delete([]byte(id))
+	})
+}
+
+//	writes the identity key of the peer to the database, it is encrypted if the storage has a passphrase
+func (b *boltStorage) SaveIdentity(peerHome string, data []byte) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	if b.passphrase != "" {
+		data, err = encrypt(data, b.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(infoBucket).Put(identityKey, data)
+	})
+}
+
+//	reads the identity key of the peer, nil if it is not created yet
+func (b *boltStorage) LoadIdentity(peerHome string) ([]byte, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		if bz := tx.Bucket(infoBucket).Get(identityKey); bz != nil {
+			data = append([]byte{}, bz...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decrypt(data, b.decrypter)
+}
+
+//	writes the callback to the database
+func (b *boltStorage) SaveCallback(peerHome string, callback models.Callback) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(callbacksBucket).Put([]byte(callback.Id), bz)
+	})
+}
+
+//	reads all pending callbacks
+func (b *boltStorage) LoadCallbacks(peerHome string) ([]models.Callback, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	callbacks := make([]models.Callback, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(callbacksBucket).ForEach(func(k, v []byte) error {
+			var callback models.Callback
+			if err := json.Unmarshal(v, &callback); err != nil {
+				logging.Warnf("invalid callback %s in the storage database, err: %+v", string(k), err)
+				return nil
+			}
+			callbacks = append(callbacks, callback)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
+
+//	removes the callback from the database
+func (b *boltStorage) DeleteCallback(peerHome string, id string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(callbacksBucket).Delete([]byte(id))
+	})
+}
+
+//	writes the signature to the database
+func (b *boltStorage) SaveSignature(peerHome string, record models.SignatureRecord) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	bz, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(signaturesBucket).Put([]byte(record.Hash), bz)
+	})
+}
+
+//	reads all cached signatures
+func (b *boltStorage) LoadSignatures(peerHome string) ([]models.SignatureRecord, error) {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]models.SignatureRecord, 0)
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(signaturesBucket).ForEach(func(k, v []byte) error {
+			var record models.SignatureRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				logging.Warnf("invalid signature %s in the storage database, err: %+v", string(k), err)
+				return nil
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+//	removes the signature from the database
+func (b *boltStorage) DeleteSignature(peerHome string, hash string) error {
+	db, err := b.open(peerHome)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(signaturesBucket).Delete([]byte(hash))
+	})
+}
+
+//	closes the database if it is opened
+func (b *boltStorage) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.db == nil {
+		return nil
+	}
+	err := b.db.Close()
+	b.db = nil
+	return err
+}
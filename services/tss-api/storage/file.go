@@ -0,0 +1,594 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/v2/tss"
+	"github.com/pkg/errors"
+	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	archiveDir         = "archive"
+	preParamsDir       = "preParams"
+	preParamsExtension = ".enc"
+	identityFile       = "identity.json"
+	callbacksDir       = "callbacks"
+	signaturesDir      = "signatures"
+	recordExtension    = ".json"
+	tmpSuffix          = ".tmp"
+)
+
+type fileStorage struct {
+	passphrase string
+	decrypter  *utils.Decrypter
+}
+
+//	Constructor of a file storage, keygen data of each key is kept in <home>/<protocol>/<keyId>
+func newFileStorage(passphrase string) Storage {
+	return &fileStorage{
+		passphrase: passphrase,
+		decrypter:  newDecrypter(passphrase),
+	}
+}
+
+//	returns the directory of a key
+func (f *fileStorage) makefilePath(peerHome string, protocol string, keyId string) string {
+	return fmt.Sprintf("%s/%s/%s", peerHome, protocol, keyId)
+}
+
+// WriteData writing given data to file in given path, data is encrypted if the storage has a passphrase
+func (f *fileStorage) WriteData(data interface{}, peerHome string, fileFormat string, protocol string, keyId string) error {
+
+	logging.Info("writing data to the file")
+
+	filePath := f.makefilePath(peerHome, protocol, keyId)
+	err := os.MkdirAll(filePath, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(filePath, fileFormat)
+
+	logging.Infof("file path: %s", path)
+	bz, err := json.MarshalIndent(&data, "", "    ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal data, err:{%v}", err)
+	}
+	if f.passphrase != "" {
+		bz, err = encrypt(bz, f.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	err = writeFile(path, bz)
+	if err != nil {
+		return err
+	}
+	logging.Infof("data was written successfully in a file: %s", path)
+	return nil
+}
+
+//	writes keygen data of all keys, keygen files of the written keys are removed if a key can not be written
+func (f *fileStorage) WriteKeys(keys []KeyData, peerHome string, fileFormat string) error {
+	for i, key := range keys {
+		err := f.WriteData(key.Data, peerHome, fileFormat, key.Protocol, key.KeyId)
+		if err == nil {
+			continue
+		}
+		for _, written := range keys[:i] {
+			dir := f.makefilePath(peerHome, written.Protocol, written.KeyId)
+			if removeErr := os.Remove(filepath.Join(dir, fileFormat)); removeErr != nil {
+				logging.Errorf("unable to remove keygen data of %s key %s, err: %+v", written.Protocol, written.KeyId, removeErr)
+				continue
+			}
+			// the directory is only removed if it is empty
+			_ = os.Remove(dir)
+		}
+		return err
+	}
+	return nil
+}
+
+//	writes data to a temp file and renames it to the path, so the file is never left half written
+func writeFile(path string, data []byte) error {
+	tmpPath := path + tmpSuffix
+	fd, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open File %s for writing, err:{%v}", tmpPath, err)
+	}
+	_, err = fd.Write(data)
+	if err == nil {
+		err = fd.Sync()
+	}
+	closeErr := fd.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("unable to write to File %s, err:{%v}", path, err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+//	reads the file and decrypts it if it is encrypted
+func (f *fileStorage) readFile(path string) ([]byte, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(bz, f.decrypter)
+}
+
+//	returns true if the file name is a keygen file
+func isKeygenFile(name string) bool {
+	return strings.Contains(name, "keygen") && !strings.HasSuffix(name, tmpSuffix)
+}
+
+//	returns path of the keygen file of the key, empty if there is no keygen file
+func (f *fileStorage) keygenFilePath(peerHome string, protocol string, keyId string) string {
+	var keygenFile string
+
+	filePath := f.makefilePath(peerHome, protocol, keyId)
+	files, err := ioutil.ReadDir(filePath)
+	if err != nil || len(files) == 0 {
+		logging.Warnf("couldn't find %s keygen of key %s %v", protocol, keyId, err)
+		return ""
+	}
+
+	for _, File := range files {
+		if isKeygenFile(File.Name()) {
+			keygenFile = File.Name()
+		}
+	}
+	return filepath.Join(filePath, keygenFile)
+}
+
+//	Loads the EDDSA keygen data of the key from the file
+func (f *fileStorage) LoadEDDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigEDDSA, *tss.PartyID, error) {
+	// locating file
+	keyFilePath := f.keygenFilePath(peerHome, models.EDDSA, keyId)
+	if keyFilePath == "" {
+		return models.TssConfigEDDSA{}, nil, errors.New(models.EDDSANoKeygenDataFoundError)
+	}
+	logging.Infof("key file path: %v", keyFilePath)
+
+	// reading file
+	bz, err := f.readFile(keyFilePath)
+	if err != nil {
+		if isPassphraseError(err) {
+			return models.TssConfigEDDSA{}, nil, err
+		}
+		return models.TssConfigEDDSA{}, nil, errors.Wrapf(
+			err,
+			"could not open the file for party in the expected location: %s. run keygen first.", keyFilePath,
+		)
+	}
+	tssConfig, partyID, err := ParseEDDSAKeygen(bz, p2pId)
+	if err != nil {
+		return models.TssConfigEDDSA{}, nil, errors.Wrapf(
+			err,
+			"could not unmarshal data for party located at: %s", keyFilePath,
+		)
+	}
+	return tssConfig, partyID, nil
+}
+
+//	Loads the ECDSA keygen data of the key from the file
+func (f *fileStorage) LoadECDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigECDSA, *tss.PartyID, error) {
+	// locating file
+	keyFilePath := f.keygenFilePath(peerHome, models.ECDSA, keyId)
+	if keyFilePath == "" {
+		return models.TssConfigECDSA{}, nil, errors.New(models.ECDSANoKeygenDataFoundError)
+	}
+	logging.Infof("key file path: %v", keyFilePath)
+
+	// reading file
+	bz, err := f.readFile(keyFilePath)
+	if err != nil {
+		if isPassphraseError(err) {
+			return models.TssConfigECDSA{}, nil, err
+		}
+		return models.TssConfigECDSA{}, nil, errors.Wrapf(
+			err,
+			"could not open the file for party in the expected location: %s. run keygen first.", keyFilePath,
+		)
+	}
+	tssConfig, partyID, err := ParseECDSAKeygen(bz, p2pId)
+	if err != nil {
+		return models.TssConfigECDSA{}, nil, errors.Wrapf(
+			err,
+			"could not unmarshal data for party located at: %s", keyFilePath,
+		)
+	}
+	return tssConfig, partyID, nil
+}
+
+//	returns true if there is a keygen file in the directory
+func hasKeygenFile(dir string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, File := range files {
+		if !File.IsDir() && isKeygenFile(File.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+//	returns keyIds of the stored keys of the protocol
+func (f *fileStorage) ListKeys(peerHome string, protocol string) ([]string, error) {
+	keyIds := make([]string, 0)
+	files, err := ioutil.ReadDir(filepath.Join(peerHome, protocol))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return keyIds, nil
+		}
+		return nil, err
+	}
+	for _, File := range files {
+		if File.IsDir() && hasKeygenFile(f.makefilePath(peerHome, protocol, File.Name())) {
+			keyIds = append(keyIds, File.Name())
+		}
+	}
+	return keyIds, nil
+}
+
+//	moves the key to the archive directory of the peer home, returns the archived path
+func (f *fileStorage) ArchiveKey(peerHome string, protocol string, keyId string) (string, error) {
+	keyPath := f.makefilePath(peerHome, protocol, keyId)
+	if !hasKeygenFile(keyPath) {
+		return "", errors.New(models.KeyNotFoundError)
+	}
+	archivePath := filepath.Join(peerHome, archiveDir, protocol)
+	err := os.MkdirAll(archivePath, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	archivePath = filepath.Join(archivePath, fmt.Sprintf("%s-%d", keyId, time.Now().Unix()))
+	err = os.Rename(keyPath, archivePath)
+	if err != nil {
+		return "", err
+	}
+	logging.Infof("%s key %s archived in %s", protocol, keyId, archivePath)
+	return archivePath, nil
+}
+
+//	moves keygen files of the single key layout (<home>/<protocol>/keygen_data.json) to the default key directory,
+//	then encrypts plaintext keygen files, pre-params and the identity if the storage has a passphrase
+func (f *fileStorage) MigrateKeys(peerHome string) error {
+	for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+		protocolPath := filepath.Join(peerHome, protocol)
+		if !hasKeygenFile(protocolPath) {
+			continue
+		}
+		keyPath := f.makefilePath(peerHome, protocol, models.DefaultKeyId)
+		if hasKeygenFile(keyPath) {
+			logging.Warnf("both old and default %s keygen files exist, old file is not migrated", protocol)
+			continue
+		}
+		err := os.MkdirAll(keyPath, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		files, err := ioutil.ReadDir(protocolPath)
+		if err != nil {
+			return err
+		}
+		for _, File := range files {
+			if File.IsDir() || !isKeygenFile(File.Name()) {
+				continue
+			}
+			err = os.Rename(filepath.Join(protocolPath, File.Name()), filepath.Join(keyPath, File.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		logging.Infof("%s keygen data migrated to key %s", protocol, models.DefaultKeyId)
+	}
+	if f.passphrase != "" {
+		err := f.encryptKeys(peerHome)
+		if err != nil {
+			return err
+		}
+		ids, err := f.preParamsFiles(peerHome)
+		if err != nil {
+			return err
+		}
+		for id := range ids {
+			err = f.encryptFile(filepath.Join(peerHome, preParamsDir, id+preParamsExtension))
+			if err != nil {
+				return err
+			}
+		}
+		return f.encryptFile(filepath.Join(peerHome, identityFile))
+	}
+	return nil
+}
+
+//	encrypts the file with the passphrase if it exists and is plaintext
+func (f *fileStorage) encryptFile(path string) error {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if encryptedContent(bz) != nil {
+		return nil
+	}
+	bz, err = encrypt(bz, f.passphrase)
+	if err != nil {
+		return err
+	}
+	err = writeFile(path, bz)
+	if err != nil {
+		return err
+	}
+	logging.Infof("plaintext file %s is encrypted", path)
+	return nil
+}
+
+//	encrypts plaintext keygen files of all keys with the passphrase
+func (f *fileStorage) encryptKeys(peerHome string) error {
+	for _, protocol := range []string{models.EDDSA, models.ECDSA} {
+		keyIds, err := f.ListKeys(peerHome, protocol)
+		if err != nil {
+			return err
+		}
+		for _, keyId := range keyIds {
+			keyPath := f.makefilePath(peerHome, protocol, keyId)
+			files, err := ioutil.ReadDir(keyPath)
+			if err != nil {
+				return err
+			}
+			for _, File := range files {
+				if File.IsDir() || !isKeygenFile(File.Name()) {
+					continue
+				}
+				path := filepath.Join(keyPath, File.Name())
+				bz, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				if encryptedContent(bz) != nil {
+					continue
+				}
+				bz, err = encrypt(bz, f.passphrase)
+				if err != nil {
+					return err
+				}
+				err = writeFile(path, bz)
+				if err != nil {
+					return err
+				}
+				logging.Infof("plaintext %s keygen data of key %s is encrypted", protocol, keyId)
+			}
+		}
+	}
+	return nil
+}
+
+//	operation history is kept in memory with the file storage
+func (f *fileStorage) SaveOperation(peerHome string, operation models.Operation) error {
+	return nil
+}
+
+//	operation history is kept in memory with the file storage
+func (f *fileStorage) LoadOperations(peerHome string) ([]models.Operation, error) {
+	return nil, nil
+}
+
+//	operation history is kept in memory with the file storage
+func (f *fileStorage) DeleteOperation(peerHome string, id string) error {
+	return nil
+}
+
+//	writes the pre-params to <home>/preParams/<id>.enc, they are encrypted if the storage has a passphrase
+func (f *fileStorage) SavePreParams(peerHome string, id string, data []byte) error {
+	dir := filepath.Join(peerHome, preParamsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	var err error
+	if f.passphrase != "" {
+		data, err = encrypt(data, f.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFile(filepath.Join(dir, id+preParamsExtension), data)
+}
+
+//	reads and decrypts all stored pre-params, the map is keyed by the pre-params id
+func (f *fileStorage) LoadPreParams(peerHome string) (map[string][]byte, error) {
+	files, err := f.preParamsFiles(peerHome)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte)
+	for id, bz := range files {
+		data, err := decrypt(bz, f.decrypter)
+		if err != nil {
+			logging.Warnf("unable to decrypt pre-params %s, err: %+v", id, err)
+			continue
+		}
+		items[id] = data
+	}
+	return items, nil
+}
+
+//	reads content of all pre-params files as they are stored, the map is keyed by the pre-params id
+func (f *fileStorage) preParamsFiles(peerHome string) (map[string][]byte, error) {
+	items := make(map[string][]byte)
+	dir := filepath.Join(peerHome, preParamsDir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return items, nil
+		}
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != preParamsExtension {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			logging.Warnf("unable to read pre-params file %s, err: %+v", path, err)
+			continue
+		}
+		items[strings.TrimSuffix(file.Name(), preParamsExtension)] = data
+	}
+	return items, nil
+}
+
+//	removes the pre-params file
+func (f *fileStorage) DeletePreParams(peerHome string, id string) error {
+	return os.Remove(filepath.Join(peerHome, preParamsDir, id+preParamsExtension))
+}
+
+//	writes the identity key of the peer to <home>/identity.json, it is encrypted if the storage has a passphrase
+func (f *fileStorage) SaveIdentity(peerHome string, data []byte) error {
+	var err error
+	if f.passphrase != "" {
+		data, err = encrypt(data, f.passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFile(filepath.Join(peerHome, identityFile), data)
+}
+
+//	reads the identity key of the peer, nil if it is not created yet
+func (f *fileStorage) LoadIdentity(peerHome string) ([]byte, error) {
+	data, err := f.readFile(filepath.Join(peerHome, identityFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+//	writes the record as json to <home>/<dir>/<id>.json
+func writeRecord(peerHome string, dir string, id string, record interface{}) error {
+	path := filepath.Join(peerHome, dir)
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return err
+	}
+	bz, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(path, id+recordExtension), bz)
+}
+
+//	reads all json records of <home>/<dir>, the map is keyed by the record id
+func readRecords(peerHome string, dir string) (map[string][]byte, error) {
+	records := make(map[string][]byte)
+	path := filepath.Join(peerHome, dir)
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != recordExtension {
+			continue
+		}
+		filePath := filepath.Join(path, file.Name())
+		bz, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			logging.Warnf("unable to read file %s, err: %+v", filePath, err)
+			continue
+		}
+		records[strings.TrimSuffix(file.Name(), recordExtension)] = bz
+	}
+	return records, nil
+}
+
+//	removes the json record <home>/<dir>/<id>.json
+func deleteRecord(peerHome string, dir string, id string) error {
+	err := os.Remove(filepath.Join(peerHome, dir, id+recordExtension))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//	writes the callback to <home>/callbacks/<id>.json
+func (f *fileStorage) SaveCallback(peerHome string, callback models.Callback) error {
+	return writeRecord(peerHome, callbacksDir, callback.Id, callback)
+}
+
+//	reads all pending callbacks
+func (f *fileStorage) LoadCallbacks(peerHome string) ([]models.Callback, error) {
+	records, err := readRecords(peerHome, callbacksDir)
+	if err != nil {
+		return nil, err
+	}
+	callbacks := make([]models.Callback, 0)
+	for id, bz := range records {
+		var callback models.Callback
+		if err := json.Unmarshal(bz, &callback); err != nil {
+			logging.Warnf("invalid callback %s, err: %+v", id, err)
+			continue
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks, nil
+}
+
+//	removes the callback file
+func (f *fileStorage) DeleteCallback(peerHome string, id string) error {
+	return deleteRecord(peerHome, callbacksDir, id)
+}
+
+//	writes the signature to <home>/signatures/<hash>.json
+func (f *fileStorage) SaveSignature(peerHome string, record models.SignatureRecord) error {
+	return writeRecord(peerHome, signaturesDir, record.Hash, record)
+}
+
+//	reads all cached signatures
+func (f *fileStorage) LoadSignatures(peerHome string) ([]models.SignatureRecord, error) {
+	items, err := readRecords(peerHome, signaturesDir)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]models.SignatureRecord, 0)
+	for hash, bz := range items {
+		var record models.SignatureRecord
+		if err := json.Unmarshal(bz, &record); err != nil {
+			logging.Warnf("invalid signature %s, err: %+v", hash, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+//	removes the signature file
+func (f *fileStorage) DeleteSignature(peerHome string, hash string) error {
+	return deleteRecord(peerHome, signaturesDir, hash)
+}
+
+//	nothing to release with the file storage
+func (f *fileStorage) Close() error {
+	return nil
+}
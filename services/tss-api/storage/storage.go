@@ -1,113 +1,146 @@
 package storage
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"strings"
+	"math/big"
 
 	"github.com/bnb-chain/tss-lib/v2/tss"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"rosen-bridge/tss-api/logger"
+	"rosen-bridge/tss-api/metrics"
 	"rosen-bridge/tss-api/models"
+	"rosen-bridge/tss-api/utils"
+)
+
+const (
+	encryptedFileVersion = 1
 )
 
 type Storage interface {
-	makefilePath(peerHome string, protocol string) string
-	WriteData(data interface{}, peerHome string, fileFormat string, protocol string) error
-	LoadEDDSAKeygen(peerHome string, p2pId string) (models.TssConfigEDDSA, *tss.PartyID, error)
-	LoadECDSAKeygen(peerHome string, p2pId string) (models.TssConfigECDSA, *tss.PartyID, error)
+	WriteData(data interface{}, peerHome string, fileFormat string, protocol string, keyId string) error
+	WriteKeys(keys []KeyData, peerHome string, fileFormat string) error
+	LoadEDDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigEDDSA, *tss.PartyID, error)
+	LoadECDSAKeygen(peerHome string, p2pId string, keyId string) (models.TssConfigECDSA, *tss.PartyID, error)
+	ListKeys(peerHome string, protocol string) ([]string, error)
+	ArchiveKey(peerHome string, protocol string, keyId string) (string, error)
+	MigrateKeys(peerHome string) error
+	SaveOperation(peerHome string, operation models.Operation) error
+	LoadOperations(peerHome string) ([]models.Operation, error)
+	DeleteOperation(peerHome string, id string) error
+	SavePreParams(peerHome string, id string, data []byte) error
+	LoadPreParams(peerHome string) (map[string][]byte, error)
+	DeletePreParams(peerHome string, id string) error
+	SaveIdentity(peerHome string, data []byte) error
+	LoadIdentity(peerHome string) ([]byte, error)
+	SaveCallback(peerHome string, callback models.Callback) error
+	LoadCallbacks(peerHome string) ([]models.Callback, error)
+	DeleteCallback(peerHome string, id string) error
+	SaveSignature(peerHome string, record models.SignatureRecord) error
+	LoadSignatures(peerHome string) ([]models.SignatureRecord, error)
+	DeleteSignature(peerHome string, hash string) error
+	Close() error
 }
 
-type storage struct{}
+//	keygen data of a key which is written with other keys at once
+type KeyData struct {
+	Protocol string
+	KeyId    string
+	Data     interface{}
+}
+
+//	format of an encrypted file, encrypted holds the hex of salt|nonce|ciphertext of the aes-gcm encryption
+type encryptedFile struct {
+	Version   int    `json:"version"`
+	Encrypted string `json:"encrypted"`
+}
 
 var logging *zap.SugaredLogger
 
-//	Constructor of a storage struct
-func NewStorage() Storage {
+//	Constructor of a storage, the backend is selected by config,
+//	key shares are encrypted with a key derived from the passphrase if it is not empty
+func NewStorage(config models.Config, passphrase string) (Storage, error) {
 	logging = logger.NewSugar("storage")
-	return &storage{}
+	if passphrase == "" {
+		logging.Warn("no passphrase is set, key shares are stored in plaintext")
+	}
+	switch config.StorageBackend {
+	case "", models.FileStorage:
+		return newFileStorage(passphrase), nil
+	case models.BoltStorage:
+		return newBoltStorage(passphrase), nil
+	default:
+		return nil, fmt.Errorf(models.WrongStorageBackendError)
+	}
 }
 
-//	Constructor of a storage struct
-func (f *storage) makefilePath(peerHome string, protocol string) string {
-	return fmt.Sprintf("%s/%s", peerHome, protocol)
+//	encrypts the data with the passphrase and wraps it in the encrypted file format
+func encrypt(data []byte, passphrase string) ([]byte, error) {
+	encrypted, err := utils.Encrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(encryptedFile{
+		Version:   encryptedFileVersion,
+		Encrypted: hex.EncodeToString(encrypted),
+	}, "", "    ")
 }
 
-// WriteData writing given data to file in given path
-func (f *storage) WriteData(data interface{}, peerHome string, fileFormat string, protocol string) error {
-
-	logging.Info("writing data to the file")
-
-	filePath := f.makefilePath(peerHome, protocol)
-	err := os.MkdirAll(filePath, os.ModePerm)
+//	returns the encrypted content of the file data, nil if the data is plaintext
+func encryptedContent(data []byte) []byte {
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Encrypted == "" {
+		return nil
+	}
+	encrypted, err := hex.DecodeString(file.Encrypted)
 	if err != nil {
-		return err
+		return nil
 	}
+	return encrypted
+}
 
-	path := filepath.Join(filePath, fileFormat)
-
-	logging.Infof("file path: %s", path)
-	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	defer func(fd *os.File) {
-		err := fd.Close()
-		if err != nil {
-			logging.Errorf("unable to close file %s, err:{%v}", path, err)
+//	decrypts the data if it is encrypted, decrypter is nil if the storage has no passphrase. plaintext data is rejected
+//	if the storage has a passphrase, since the migration encrypts it, it is only found if it is replaced afterwards
+func decrypt(data []byte, decrypter *utils.Decrypter) ([]byte, error) {
+	encrypted := encryptedContent(data)
+	if encrypted == nil {
+		if decrypter != nil {
+			metrics.PlaintextRejected()
+			return nil, errors.New(models.PlaintextDataError)
 		}
-	}(fd)
-
-	if err != nil {
-		return fmt.Errorf("unable to open File %s for writing, err:{%v}", path, err)
+		return data, nil
 	}
-	bz, err := json.MarshalIndent(&data, "", "    ")
-	if err != nil {
-		return fmt.Errorf("unable to marshal data, err:{%v}", err)
+	if decrypter == nil {
+		return nil, errors.New(models.PassphraseRequiredError)
 	}
-	_, err = fd.Write(bz)
+	data, err := decrypter.Decrypt(encrypted)
 	if err != nil {
-		return fmt.Errorf("unable to write to File %s", path)
+		return nil, errors.New(models.WrongPassphraseError)
 	}
-	logging.Infof("data was written successfully in a file: %s", path)
-	return nil
+	return data, nil
 }
 
-//	Loads the EDDSA keygen data from the file
-func (f *storage) LoadEDDSAKeygen(peerHome string, p2pId string) (models.TssConfigEDDSA, *tss.PartyID, error) {
-	// locating file
-	var keygenFile string
-
-	filePath := f.makefilePath(peerHome, models.EDDSA)
-	files, err := ioutil.ReadDir(filePath)
-	if err != nil || len(files) == 0 {
-		logging.Warnf("couldn't find eddsa keygen %v", err)
-		return models.TssConfigEDDSA{}, nil, errors.New(models.EDDSANoKeygenDataFoundError)
+//	returns the decrypter of the passphrase, nil if there is no passphrase
+func newDecrypter(passphrase string) *utils.Decrypter {
+	if passphrase == "" {
+		return nil
 	}
+	return utils.NewDecrypter(passphrase)
+}
 
-	for _, File := range files {
-		if strings.Contains(File.Name(), "keygen") {
-			keygenFile = File.Name()
-		}
-	}
-	keyFilePath := filepath.Join(filePath, keygenFile)
-	logging.Infof("key file path: %v", keyFilePath)
+//	returns true if the error is about the passphrase of encrypted key shares
+func isPassphraseError(err error) bool {
+	return err.Error() == models.PassphraseRequiredError || err.Error() == models.WrongPassphraseError ||
+		err.Error() == models.PlaintextDataError
+}
 
-	// reading file
-	bz, err := ioutil.ReadFile(keyFilePath)
-	if err != nil {
-		return models.TssConfigEDDSA{}, nil, errors.Wrapf(
-			err,
-			"could not open the file for party in the expected location: %s. run keygen first.", keyFilePath,
-		)
-	}
+//	parses eddsa keygen data and creates the party ID of the peer
+func ParseEDDSAKeygen(bz []byte, p2pId string) (models.TssConfigEDDSA, *tss.PartyID, error) {
 	var tssConfig models.TssConfigEDDSA
-	if err = json.Unmarshal(bz, &tssConfig); err != nil {
-		return models.TssConfigEDDSA{}, nil, errors.Wrapf(
-			err,
-			"could not unmarshal data for party located at: %s", keyFilePath,
-		)
+	if err := json.Unmarshal(bz, &tssConfig); err != nil {
+		return models.TssConfigEDDSA{}, nil, err
 	}
 
 	//creating data from file
@@ -115,50 +148,14 @@ func (f *storage) LoadEDDSAKeygen(peerHome string, p2pId string) (models.TssConf
 		kbxj.SetCurve(tss.Edwards())
 	}
 	tssConfig.KeygenData.EDDSAPub.SetCurve(tss.Edwards())
-	id := p2pId
-	pMoniker := fmt.Sprintf("tssPeer/%s", p2pId)
-	partyID := tss.NewPartyID(id, pMoniker, tssConfig.KeygenData.ShareID)
-
-	var parties tss.UnSortedPartyIDs
-	parties = append(parties, partyID)
-	sortedPIDs := tss.SortPartyIDs(parties)
-	return tssConfig, sortedPIDs[0], nil
+	return tssConfig, newPartyID(p2pId, tssConfig.KeygenData.ShareID), nil
 }
 
-//	Loads the ECDSA keygen data from the file
-func (f *storage) LoadECDSAKeygen(peerHome string, p2pId string) (models.TssConfigECDSA, *tss.PartyID, error) {
-	// locating file
-	var keygenFile string
-
-	filePath := f.makefilePath(peerHome, models.ECDSA)
-	files, err := ioutil.ReadDir(filePath)
-	if err != nil || len(files) == 0 {
-		logging.Warnf("couldn't find ecdsa keygen %v", err)
-		return models.TssConfigECDSA{}, nil, errors.New(models.ECDSANoKeygenDataFoundError)
-	}
-
-	for _, File := range files {
-		if strings.Contains(File.Name(), "keygen") {
-			keygenFile = File.Name()
-		}
-	}
-	keyFilePath := filepath.Join(filePath, keygenFile)
-	logging.Infof("key file path: %v", keyFilePath)
-
-	// reading file
-	bz, err := ioutil.ReadFile(keyFilePath)
-	if err != nil {
-		return models.TssConfigECDSA{}, nil, errors.Wrapf(
-			err,
-			"could not open the file for party in the expected location: %s. run keygen first.", keyFilePath,
-		)
-	}
+//	parses ecdsa keygen data and creates the party ID of the peer
+func ParseECDSAKeygen(bz []byte, p2pId string) (models.TssConfigECDSA, *tss.PartyID, error) {
 	var tssConfig models.TssConfigECDSA
-	if err = json.Unmarshal(bz, &tssConfig); err != nil {
-		return models.TssConfigECDSA{}, nil, errors.Wrapf(
-			err,
-			"could not unmarshal data for party located at: %s", keyFilePath,
-		)
+	if err := json.Unmarshal(bz, &tssConfig); err != nil {
+		return models.TssConfigECDSA{}, nil, err
 	}
 
 	//creating data from file
@@ -166,12 +163,17 @@ func (f *storage) LoadECDSAKeygen(peerHome string, p2pId string) (models.TssConf
 		kbxj.SetCurve(tss.S256())
 	}
 	tssConfig.KeygenData.ECDSAPub.SetCurve(tss.S256())
+	return tssConfig, newPartyID(p2pId, tssConfig.KeygenData.ShareID), nil
+}
+
+//	creates the party ID of the peer with its share ID
+func newPartyID(p2pId string, shareID *big.Int) *tss.PartyID {
 	id := p2pId
 	pMoniker := fmt.Sprintf("tssPeer/%s", p2pId)
-	partyID := tss.NewPartyID(id, pMoniker, tssConfig.KeygenData.ShareID)
+	partyID := tss.NewPartyID(id, pMoniker, shareID)
 
 	var parties tss.UnSortedPartyIDs
 	parties = append(parties, partyID)
 	sortedPIDs := tss.SortPartyIDs(parties)
-	return tssConfig, sortedPIDs[0], nil
+	return sortedPIDs[0]
 }
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+	// the key cache of a decrypter is cleared when it has this many keys
+	maxCachedKeys = 1024
+)
+
+//	decrypts data encrypted with a secret, keys derived by scrypt are cached by their salt, so data which is read
+//	again (e.g. a key share for every public key request) is decrypted without running scrypt
+type Decrypter struct {
+	secret string
+	lock   sync.Mutex
+	keys   map[string][]byte
+}
+
+//	derives an aes key from the secret and salt using scrypt
+func deriveKey(secret string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, keySize)
+}
+
+//	encrypts data with aes-gcm using a key derived from the secret, output is salt|nonce|ciphertext
+func Encrypt(data []byte, secret string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(salt, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+//	decrypts data encrypted by Encrypt with the same secret
+func Decrypt(data []byte, secret string) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	key, err := deriveKey(secret, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return decryptWithKey(data, key)
+}
+
+//	decrypts data encrypted by Encrypt with the key derived from its salt
+func decryptWithKey(data []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < saltSize+gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	nonce := data[saltSize : saltSize+gcm.NonceSize()]
+	return gcm.Open(nil, nonce, data[saltSize+gcm.NonceSize():], nil)
+}
+
+//	Constructor of a decrypter of the secret
+func NewDecrypter(secret string) *Decrypter {
+	return &Decrypter{
+		secret: secret,
+		keys:   make(map[string][]byte),
+	}
+}
+
+//	decrypts data encrypted by Encrypt with the secret of the decrypter, only keys which decrypt the data are cached
+func (d *Decrypter) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	salt := string(data[:saltSize])
+	d.lock.Lock()
+	key, cached := d.keys[salt]
+	d.lock.Unlock()
+	if !cached {
+		var err error
+		key, err = deriveKey(d.secret, data[:saltSize])
+		if err != nil {
+			return nil, err
+		}
+	}
+	plain, err := decryptWithKey(data, key)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		d.lock.Lock()
+		if len(d.keys) >= maxCachedKeys {
+			d.keys = make(map[string][]byte)
+		}
+		d.keys[salt] = key
+		d.lock.Unlock()
+	}
+	return plain, nil
+}
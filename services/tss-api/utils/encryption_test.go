@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	data := []byte(`{"keygenData":"share"}`)
+	encrypted, err := Encrypt(data, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, data) {
+		t.Fatal("data is not encrypted")
+	}
+	again, err := Encrypt(data, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(encrypted, again) {
+		t.Error("encryptions of the same data are equal, salt and nonce are not random")
+	}
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0xff
+	tests := []struct {
+		name      string
+		data      []byte
+		secret    string
+		wantError bool
+	}{
+		{name: "right passphrase", data: encrypted, secret: "passphrase"},
+		{name: "wrong passphrase", data: encrypted, secret: "other", wantError: true},
+		{name: "empty passphrase", data: encrypted, secret: "", wantError: true},
+		{name: "tampered data", data: tampered, secret: "passphrase", wantError: true},
+		{name: "short data", data: encrypted[:saltSize+4], secret: "passphrase", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, decrypt := range []func([]byte) ([]byte, error){
+				func(data []byte) ([]byte, error) { return Decrypt(data, tt.secret) },
+				NewDecrypter(tt.secret).Decrypt,
+			} {
+				plain, err := decrypt(tt.data)
+				if tt.wantError {
+					if err == nil {
+						t.Fatal("data is decrypted")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(plain, data) {
+					t.Fatalf("decrypted data = %s, want %s", plain, data)
+				}
+			}
+		})
+	}
+}
+
+func TestDecrypterCache(t *testing.T) {
+	first, err := Encrypt([]byte("first"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Encrypt([]byte("second"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypter := NewDecrypter("passphrase")
+	for i := 0; i < 3; i++ {
+		for _, data := range [][]byte{first, second} {
+			if _, err := decrypter.Decrypt(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(decrypter.keys) != 2 {
+		t.Errorf("cached keys = %d, want one key of each salt", len(decrypter.keys))
+	}
+
+	// a key which does not decrypt the data is not cached
+	wrong := NewDecrypter("other")
+	if _, err := wrong.Decrypt(first); err == nil {
+		t.Fatal("data is decrypted with a wrong passphrase")
+	}
+	if len(wrong.keys) != 0 {
+		t.Error("key of a wrong passphrase is cached")
+	}
+}
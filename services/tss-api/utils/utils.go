@@ -1,20 +1,41 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/elliptic"
 	"encoding/hex"
 	"fmt"
 	"github.com/bnb-chain/tss-lib/v2/tss"
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/decred/dcrd/dcrec/edwards/v2"
+	"io"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/blake2b"
 	"rosen-bridge/tss-api/models"
 )
 
+const (
+	PassphraseEnv       = "TSS_PASSPHRASE"
+	BundlePassphraseEnv = "TSS_BUNDLE_PASSPHRASE"
+	ApiSecretEnv        = "TSS_API_SECRET"
+)
+
+// time to drain channels of a stopped party, rounds of tss-lib which are still running finish in this time
+const partyDrainTimeout = 2 * time.Minute
+
+var keyIdPattern = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
+
 //	get absolute address of an address
 func GetAbsoluteAddress(address string) (string, error) {
 	var absAddress string
@@ -50,6 +71,190 @@ func GetPKFromECDSAPub(x *big.Int, y *big.Int) []byte {
 	return elliptic.MarshalCompressed(tss.EC(), x, y)
 }
 
+// GetUncompressedPKFromECDSAPub returns the uncompressed public key Serialized from an ECDSA public key.
+func GetUncompressedPKFromECDSAPub(x *big.Int, y *big.Int) []byte {
+	return elliptic.Marshal(tss.EC(), x, y)
+}
+
+//	parses a bip32 derivation path (e.g. m/0/1), only non-hardened indices are accepted. the path must be m or start
+//	with m/, an empty path is the same as m
+func ParseDerivationPath(path string) ([]uint32, error) {
+	path = strings.TrimSpace(path)
+	indices := make([]uint32, 0)
+	if path == "" || path == "m" {
+		return indices, nil
+	}
+	if !strings.HasPrefix(path, "m/") {
+		return nil, fmt.Errorf(models.DerivationPathPrefixError)
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(path, "m/"), "/") {
+		if part == "" {
+			return nil, fmt.Errorf(models.EmptyDerivationIndexError)
+		}
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H") {
+			return nil, fmt.Errorf(models.HardenedDerivationError)
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf(models.WrongDerivationPathError)
+		}
+		if index >= 1<<31 {
+			return nil, fmt.Errorf(models.HardenedDerivationError)
+		}
+		indices = append(indices, uint32(index))
+	}
+	return indices, nil
+}
+
+//	returns the party of the operation which sent the message, the sender is found by its id and its index and key
+//	must match, so a peer can not pose as another party
+func PartySender(from *tss.PartyID, partyIds ...tss.SortedPartyIDs) (*tss.PartyID, error) {
+	if from == nil {
+		return nil, fmt.Errorf(models.UnknownPartyError)
+	}
+	for _, ids := range partyIds {
+		for _, partyId := range ids {
+			if partyId.Id == from.Id && partyId.Index == from.Index && bytes.Equal(partyId.Key, from.Key) {
+				return partyId, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(models.UnknownPartyError)
+}
+
+//	drains out and end channels of a stopped party in the background, so the running rounds of tss-lib do not block
+//	on sending, stops when all parties are ended (nil end channels are ended already) or the timeout passes
+func DrainParty[T any](outCh chan tss.Message, endChs ...chan T) {
+	running := make([]chan T, 0, len(endChs))
+	for _, endCh := range endChs {
+		if endCh != nil {
+			running = append(running, endCh)
+		}
+	}
+	if len(running) == 0 {
+		return
+	}
+	stop := make(chan struct{})
+	ended := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, endCh := range running {
+		wg.Add(1)
+		go func(endCh chan T) {
+			defer wg.Done()
+			select {
+			case <-endCh:
+			case <-stop:
+			}
+		}(endCh)
+	}
+	go func() {
+		wg.Wait()
+		close(ended)
+	}()
+	go func() {
+		defer close(stop)
+		timer := time.NewTimer(partyDrainTimeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-outCh:
+			case <-ended:
+				return
+			case <-timer.C:
+				return
+			}
+		}
+	}()
+}
+
+//	checks the keyId of a request and returns it, the default key is used if it is empty
+func CheckKeyId(keyId string) (string, error) {
+	if keyId == "" {
+		return models.DefaultKeyId, nil
+	}
+	if len(keyId) > 64 || !keyIdPattern.MatchString(keyId) {
+		return "", fmt.Errorf(models.InvalidKeyIdError)
+	}
+	return keyId, nil
+}
+
+//	returns the messageId of an operation on a key, messageIds of the default key are not changed
+func KeyMessageId(messageId string, keyId string) string {
+	if keyId == "" || keyId == models.DefaultKeyId {
+		return messageId
+	}
+	return fmt.Sprintf("%s-%s", messageId, keyId)
+}
+
+//	returns the hash of a sign request which identifies its signature, hex of blake2b of the crypto, keyId,
+//	message, chain code and derivation path
+func SignatureHash(crypto string, keyId string, message string, chainCode string, derivationPath []uint32) string {
+	path := make([]string, 0, len(derivationPath))
+	for _, index := range derivationPath {
+		path = append(path, strconv.FormatUint(uint64(index), 10))
+	}
+	hash := blake2b.Sum256([]byte(strings.Join([]string{
+		crypto,
+		keyId,
+		strings.ToLower(message),
+		strings.ToLower(chainCode),
+		strings.Join(path, "/"),
+	}, "|")))
+	return hex.EncodeToString(hash[:])
+}
+
+//	reads the passphrase of key shares from the file, the TSS_PASSPHRASE env variable or the first line of stdin,
+//	returns empty string if no source is given
+func ReadPassphrase(file string, fromStdin bool) (string, error) {
+	return readPassphrase(file, PassphraseEnv, fromStdin)
+}
+
+//	reads the passphrase of a key share bundle from the file or the TSS_BUNDLE_PASSPHRASE env variable,
+//	a bundle is never written or read without a passphrase
+func ReadBundlePassphrase(file string) (string, error) {
+	passphrase, err := readPassphrase(file, BundlePassphraseEnv, false)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf(models.EmptyPassphraseError)
+	}
+	return passphrase, nil
+}
+
+//	reads the shared secret of the control API from the file or the TSS_API_SECRET env variable,
+//	returns empty string if no source is given
+func ReadApiSecret(file string) (string, error) {
+	return readPassphrase(file, ApiSecretEnv, false)
+}
+
+//	reads a passphrase from the file, the env variable or the first line of stdin
+func readPassphrase(file string, env string, fromStdin bool) (string, error) {
+	var passphrase string
+	switch {
+	case file != "":
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	case os.Getenv(env) != "":
+		passphrase = os.Getenv(env)
+	case fromStdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		passphrase = strings.TrimRight(line, "\r\n")
+	default:
+		return "", nil
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf(models.EmptyPassphraseError)
+	}
+	return passphrase, nil
+}
+
 //	reads in config file and ENV variables if set.
 func InitConfig(configFile string) (models.Config, error) {
 	// Search config in home directory with name "default" (without extension).
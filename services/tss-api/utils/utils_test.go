@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bnb-chain/tss-lib/v2/tss"
+)
+
+func TestDrainParty(t *testing.T) {
+	outCh := make(chan tss.Message, 1)
+	endCh := make(chan *struct{}, 1)
+
+	// a round of a stopped party which still sends its messages
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			outCh <- nil
+		}
+		endCh <- &struct{}{}
+		close(sent)
+	}()
+
+	DrainParty(outCh, endCh)
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sender of the stopped party is blocked")
+	}
+}
+
+func TestDrainPartyEnded(t *testing.T) {
+	outCh := make(chan tss.Message, 1)
+	var endCh chan *struct{}
+
+	// all parties are ended, nothing is drained
+	DrainParty(outCh, endCh)
+	outCh <- nil
+	time.Sleep(100 * time.Millisecond)
+	if len(outCh) != 1 {
+		t.Fatal("channel of the ended party is drained")
+	}
+}